@@ -26,6 +26,22 @@ import (
 	"github.com/intelsdi-x/snap/core/ctypes"
 )
 
+// NotFoundError is returned when configuration item is not defined in config
+type NotFoundError struct {
+	Name   string
+	Config string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("Cannot find %v in %v", e.Name, e.Config)
+}
+
+// IsNotFound checks if err reports configuration item which is not defined
+func IsNotFound(err error) bool {
+	_, ok := err.(NotFoundError)
+	return ok
+}
+
 // getConfigItemValue returns value of configuration item
 func getConfigItemValue(item ctypes.ConfigValue) (interface{}, error) {
 
@@ -65,7 +81,7 @@ func GetGlobalConfigItem(cfg plugin.ConfigType, name string) (interface{}, error
 		}
 	}
 
-	return nil, fmt.Errorf("Cannot find %v in Global Config", name)
+	return nil, NotFoundError{Name: name, Config: "Global Config"}
 }
 
 // GetGlobalConfigItems returns map to values of multiple configuration items defined in Plugin Global Config and specified in 'names' slice
@@ -77,7 +93,7 @@ func GetGlobalConfigItems(cfg plugin.ConfigType, names []string) (map[string]int
 		if cfg.ConfigDataNode != nil && len(cfg.Table()) > 0 {
 			item, ok := cfg.Table()[name]
 			if !ok {
-				return nil, fmt.Errorf("Cannot find %v in Global Config", name)
+				return nil, NotFoundError{Name: name, Config: "Global Config"}
 			}
 
 			val, err := getConfigItemValue(item)
@@ -86,7 +102,7 @@ func GetGlobalConfigItems(cfg plugin.ConfigType, names []string) (map[string]int
 			}
 			result[name] = val
 		} else {
-			return nil, fmt.Errorf("Cannot find %v in Global Config", name)
+			return nil, NotFoundError{Name: name, Config: "Global Config"}
 		}
 	}
 
@@ -103,7 +119,7 @@ func GetMetricConfigItem(metric plugin.MetricType, name string) (interface{}, er
 		}
 	}
 
-	return nil, fmt.Errorf("Cannot find %v in Metrics Config", name)
+	return nil, NotFoundError{Name: name, Config: "Metrics Config"}
 }
 
 // GetMetricConfigItems returns map to values of multiple configuration items defined in Metric Config and specified in 'names' slice
@@ -116,7 +132,7 @@ func GetMetricConfigItems(metric plugin.MetricType, names []string) (map[string]
 		if metric.Config() != nil && len(metric.Config().Table()) > 0 {
 			item, ok := metric.Config().Table()[name]
 			if !ok {
-				return nil, fmt.Errorf("Cannot find %v in Metrics Config", name)
+				return nil, NotFoundError{Name: name, Config: "Metrics Config"}
 			}
 
 			val, err := getConfigItemValue(item)
@@ -125,7 +141,7 @@ func GetMetricConfigItems(metric plugin.MetricType, names []string) (map[string]
 			}
 			result[name] = val
 		} else {
-			return nil, fmt.Errorf("Cannot find %v in Metrics Config", name)
+			return nil, NotFoundError{Name: name, Config: "Metrics Config"}
 		}
 	}
 
@@ -79,6 +79,8 @@ func TestGetGlobalConfigItem(t *testing.T) {
 		cfg.AddItem("foo", ctypes.ConfigValueStr{Value: "foo"})
 		result, err := GetGlobalConfigItem(cfg, "foo_not_exist")
 		So(err, ShouldNotBeNil)
+		So(IsNotFound(err), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "Cannot find foo_not_exist in Global Config")
 		So(result, ShouldBeNil)
 	})
 
@@ -0,0 +1,299 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formula
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/config"
+	"github.com/intelsdi-x/snap-plugin-utilities/ns"
+)
+
+// Interval is name of built-in variable holding number of seconds since
+// previous evaluation of derived metric, unless it is bound in inputs
+const Interval = "interval"
+
+// DerivedMetric describes metric computed with formula from values of other
+// metrics. Inputs bind variables of formula to namespaces of those metrics.
+//
+// Example in YAML:
+//
+//	# list of derived metrics
+//	- namespace: /intel/disk/sda/bits_read
+//	  formula: bytes_read*8/interval
+//	  unit: b/s
+//	  inputs:
+//	    bytes_read: /intel/disk/sda/bytes_read
+type DerivedMetric struct {
+	Namespace   string            `json:"namespace" yaml:"namespace"`
+	Formula     string            `json:"formula" yaml:"formula"`
+	Inputs      map[string]string `json:"inputs" yaml:"inputs"`
+	Unit        string            `json:"unit,omitempty" yaml:"unit"`
+	Description string            `json:"description,omitempty" yaml:"description"`
+}
+
+// Derived computes derived metrics from collected ones
+type Derived struct {
+	definitions []DerivedMetric
+	formulas    []*Formula
+
+	mutex sync.Mutex
+	// time of previous evaluation of each definition, for Interval
+	last []time.Time
+}
+
+// ParseDerived decodes list of derived metric definitions from JSON or YAML
+// document and parses their formulas, so configuration errors are reported
+// when plugin loads its configuration. Documents starting with '[' are decoded as JSON.
+func ParseDerived(data []byte) (*Derived, error) {
+	definitions := []DerivedMetric{}
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &definitions)
+	} else {
+		err = yaml.Unmarshal(data, &definitions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode derived metrics: %v", err)
+	}
+	return NewDerived(definitions...)
+}
+
+// LoadDerived reads derived metric definitions from file, see ParseDerived
+func LoadDerived(path string) (*Derived, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDerived(data)
+}
+
+// DerivedFromConfig parses derived metric definitions given as string item
+// `name` of plugin global config. Missing item means no derived metrics.
+func DerivedFromConfig(cfg plugin.ConfigType, name string) (*Derived, error) {
+	item, err := config.GetGlobalConfigItem(cfg, name)
+	if config.IsNotFound(err) {
+		return NewDerived()
+	}
+	if err != nil {
+		return nil, err
+	}
+	s, ok := item.(string)
+	if !ok {
+		return nil, fmt.Errorf("Config item %v must be a string with derived metrics, got %T", name, item)
+	}
+	return ParseDerived([]byte(s))
+}
+
+// NewDerived validates definitions and parses their formulas
+func NewDerived(definitions ...DerivedMetric) (*Derived, error) {
+	d := &Derived{definitions: definitions, last: make([]time.Time, len(definitions))}
+	for i, def := range definitions {
+		f, err := def.parse()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid derived metric #%d (%s): %v", i, def.Namespace, err)
+		}
+		d.formulas = append(d.formulas, f)
+	}
+	return d, nil
+}
+
+func (def DerivedMetric) parse() (*Formula, error) {
+	if def.Namespace == "" || def.Formula == "" {
+		return nil, fmt.Errorf("namespace and formula are required")
+	}
+	if err := validateNamespace(def.Namespace); err != nil {
+		return nil, err
+	}
+	f, err := Parse(def.Formula)
+	if err != nil {
+		return nil, err
+	}
+	for variable, namespace := range def.Inputs {
+		if err := validateNamespace(namespace); err != nil {
+			return nil, fmt.Errorf("input %q: %v", variable, err)
+		}
+		if err := f.Bind(variable, namespace); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range f.Variables() {
+		if _, ok := def.Inputs[v]; !ok && v != Interval {
+			return nil, fmt.Errorf("variable %q has no input namespace", v)
+		}
+	}
+	return f, nil
+}
+
+// validateNamespace checks that namespace like "/intel/mem/used" has
+// only non-empty elements without characters not allowed by ns package
+func validateNamespace(namespace string) error {
+	for _, part := range strings.Split(strings.Trim(namespace, "/"), "/") {
+		if part == "" {
+			return fmt.Errorf("namespace %q contains empty element", namespace)
+		}
+		if err := ns.ValidateMetricNamespacePart(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Inputs returns sorted namespaces of metrics used by derived metrics,
+// plugin has to collect them to compute derived ones
+func (d *Derived) Inputs() []string {
+	seen := map[string]bool{}
+	inputs := []string{}
+	for _, def := range d.definitions {
+		for _, namespace := range def.Inputs {
+			if !seen[namespace] {
+				seen[namespace] = true
+				inputs = append(inputs, namespace)
+			}
+		}
+	}
+	sort.Strings(inputs)
+	return inputs
+}
+
+// MetricTypes returns catalog of derived metrics for GetMetricTypes
+func (d *Derived) MetricTypes() []plugin.MetricType {
+	mts := []plugin.MetricType{}
+	for _, def := range d.definitions {
+		mts = append(mts, plugin.MetricType{
+			Namespace_:   def.namespace(),
+			Unit_:        def.Unit,
+			Description_: def.Description,
+		})
+	}
+	return mts
+}
+
+func (def DerivedMetric) namespace() core.Namespace {
+	return core.NewNamespace(strings.Split(strings.Trim(def.Namespace, "/"), "/")...)
+}
+
+// Collect computes derived metrics from collected metrics. Value is
+// float64, timestamp and tags are taken from the newest of input metrics.
+// Derived metrics whose inputs were not collected, or which use rate or
+// Interval and have no previous sample yet, are skipped.
+// Evaluation errors (e.g. division by zero) are returned together with
+// metrics which were computed.
+func (d *Derived) Collect(metrics []plugin.MetricType) ([]plugin.MetricType, error) {
+	byNamespace := make(map[string]plugin.MetricType, len(metrics))
+	for _, m := range metrics {
+		byNamespace[normalizeNamespace(m.Namespace().String())] = m
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	results := []plugin.MetricType{}
+	var first error
+	for i, def := range d.definitions {
+		values := Values{}
+		var newest plugin.MetricType
+		complete := true
+		usesInterval := false
+		// variables in order of use, so ties of timestamps are resolved deterministically
+		for _, variable := range d.formulas[i].Variables() {
+			namespace, ok := def.Inputs[variable]
+			if !ok {
+				// only Interval may be unbound, see parse
+				usesInterval = true
+				continue
+			}
+			m, ok := byNamespace[normalizeNamespace(namespace)]
+			if !ok {
+				complete = false
+				break
+			}
+			value, err := ToFloat(m.Data())
+			if err != nil {
+				complete = false
+				if first == nil {
+					first = fmt.Errorf("Derived metric %s: input %s: %v", def.Namespace, namespace, err)
+				}
+				break
+			}
+			values[variable] = value
+			if newest.Namespace() == nil || m.Timestamp().After(newest.Timestamp()) {
+				newest = m
+			}
+		}
+		if !complete {
+			continue
+		}
+		now := newest.Timestamp()
+		if now.IsZero() {
+			now = time.Now()
+		}
+		if usesInterval {
+			last := d.last[i]
+			d.last[i] = now
+			if last.IsZero() || !now.After(last) {
+				continue
+			}
+			values[Interval] = now.Sub(last).Seconds()
+		}
+
+		value, err := d.formulas[i].Eval(values, now)
+		if err == ErrNotReady {
+			continue
+		}
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("Derived metric %s: %v", def.Namespace, err)
+			}
+			continue
+		}
+		results = append(results, plugin.MetricType{
+			Namespace_:   def.namespace(),
+			Data_:        value,
+			Timestamp_:   now,
+			Tags_:        copyTags(newest.Tags()),
+			Unit_:        def.Unit,
+			Description_: def.Description,
+		})
+	}
+	return results, first
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+	return c
+}
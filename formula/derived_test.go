@@ -0,0 +1,180 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formula
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const derivedYAML = `
+- namespace: /intel/disk/sda/bits_read
+  formula: bytes_read*8/interval
+  unit: b/s
+  inputs:
+    bytes_read: /intel/disk/sda/bytes_read
+- namespace: /intel/mem/utilization
+  formula: ratio(used, total) * 100
+  inputs:
+    used: /intel/mem/used
+    total: /intel/mem/total
+`
+
+// unsupported is config value of type not handled by config package
+type unsupported struct{}
+
+func (unsupported) Type() string { return "list" }
+
+func TestParseDerived(t *testing.T) {
+	Convey("Given derived metrics in YAML", t, func() {
+		d, err := ParseDerived([]byte(derivedYAML))
+		So(err, ShouldBeNil)
+
+		Convey("Then inputs are listed", func() {
+			So(d.Inputs(), ShouldResemble, []string{"/intel/disk/sda/bytes_read", "/intel/mem/total", "/intel/mem/used"})
+		})
+
+		Convey("Then catalog of derived metrics is provided", func() {
+			mts := d.MetricTypes()
+			So(len(mts), ShouldEqual, 2)
+			So(mts[0].Namespace().String(), ShouldEqual, "/intel/disk/sda/bits_read")
+			So(mts[0].Unit(), ShouldEqual, "b/s")
+		})
+	})
+
+	Convey("Given derived metrics in JSON", t, func() {
+		_, err := ParseDerived([]byte(`[{"namespace": "/intel/x", "formula": "a+1", "inputs": {"a": "/intel/a"}}]`))
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Given invalid definitions", t, func() {
+		cases := map[string]string{
+			`[{"namespace": "/intel/x", "formula": "a+"}]`:                               `Invalid derived metric #0 (/intel/x): Invalid formula "a+": unexpected end of expression`,
+			`[{"namespace": "/intel/x", "formula": "a+b", "inputs": {"a": "/intel/a"}}]`: `Invalid derived metric #0 (/intel/x): variable "b" has no input namespace`,
+			`[{"namespace": "/intel/x", "formula": "a", "inputs": {"b": "/intel/b"}}]`:   `Invalid derived metric #0 (/intel/x): Formula "a" has no variable "b"`,
+			`[{"namespace": "/intel/x y", "formula": "1"}]`:                              `Invalid derived metric #0 (/intel/x y): Namespace contains not allowed chars, namespace part: x y, not allowed char:  `,
+			`[{"namespace": "/intel/x", "formula": "a", "inputs": {"a": "/intel//a"}}]`:  `Invalid derived metric #0 (/intel/x): input "a": namespace "/intel//a" contains empty element`,
+			`[{"namespace": "/intel/x", "formula": "a", "inputs": {"a": ""}}]`:           `Invalid derived metric #0 (/intel/x): input "a": namespace "" contains empty element`,
+			`[{"formula": "1"}]`:        `Invalid derived metric #0 (): namespace and formula are required`,
+			`[{"namespace": "/intel/x"`: `Cannot decode derived metrics: unexpected end of JSON input`,
+		}
+		for doc, expected := range cases {
+			_, err := ParseDerived([]byte(doc))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, expected)
+		}
+	})
+
+	Convey("Given derived metrics in plugin config", t, func() {
+		cfg := plugin.NewPluginConfigType()
+		cfg.AddItem("derived", ctypes.ConfigValueStr{Value: `[{"namespace": "/intel/x", "formula": "a/"}]`})
+
+		Convey("Then errors are reported when config is loaded", func() {
+			_, err := DerivedFromConfig(cfg, "derived")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then missing item means no derived metrics", func() {
+			d, err := DerivedFromConfig(cfg, "other")
+			So(err, ShouldBeNil)
+			So(d.MetricTypes(), ShouldBeEmpty)
+		})
+
+		Convey("Then item of unsupported type is reported", func() {
+			cfg.AddItem("list", unsupported{})
+			_, err := DerivedFromConfig(cfg, "list")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestDerivedCollect(t *testing.T) {
+	Convey("Given derived metrics", t, func() {
+		d, err := ParseDerived([]byte(derivedYAML))
+		So(err, ShouldBeNil)
+		start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		metric := func(namespace string, data interface{}, ts time.Time) plugin.MetricType {
+			return plugin.MetricType{
+				Namespace_: core.NewNamespace(strings.Split(namespace[1:], "/")...),
+				Data_:      data,
+				Timestamp_: ts,
+				Tags_:      map[string]string{"host": "h1"},
+			}
+		}
+		collect := func(read, used, total interface{}, ts time.Time) ([]plugin.MetricType, error) {
+			metrics := []plugin.MetricType{
+				metric("/intel/disk/sda/bytes_read", read, ts),
+				metric("/intel/mem/used", used, ts),
+				metric("/intel/mem/total", total, ts),
+			}
+			return d.Collect(metrics)
+		}
+
+		Convey("When metrics are collected for the first time", func() {
+			mts, err := collect(uint64(1000), 25, 200, start)
+			So(err, ShouldBeNil)
+
+			Convey("Then metrics using interval are skipped", func() {
+				So(len(mts), ShouldEqual, 1)
+				So(mts[0].Namespace().String(), ShouldEqual, "/intel/mem/utilization")
+				So(mts[0].Data(), ShouldEqual, 12.5)
+				So(mts[0].Tags(), ShouldResemble, map[string]string{"host": "h1"})
+				So(mts[0].Timestamp(), ShouldEqual, start)
+			})
+
+			Convey("When metrics are collected again", func() {
+				mts, err := collect(uint64(3000), 50, 200, start.Add(2*time.Second))
+				So(err, ShouldBeNil)
+
+				Convey("Then interval is seconds since previous collection", func() {
+					So(len(mts), ShouldEqual, 2)
+					So(mts[0].Namespace().String(), ShouldEqual, "/intel/disk/sda/bits_read")
+					So(mts[0].Data(), ShouldEqual, 12000)
+					So(mts[0].Unit(), ShouldEqual, "b/s")
+				})
+			})
+		})
+
+		Convey("When input is not numeric", func() {
+			mts, err := collect("n/a", 25, 200, start)
+
+			Convey("Then error is returned with other derived metrics", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "Derived metric /intel/disk/sda/bits_read: input /intel/disk/sda/bytes_read: value n/a of type string is not a number")
+				So(len(mts), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When inputs are not collected", func() {
+			mts, err := d.Collect(nil)
+
+			Convey("Then nothing is derived", func() {
+				So(err, ShouldBeNil)
+				So(mts, ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,265 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/stack"
+)
+
+// ErrNotReady is returned when formula uses rate and there is no previous
+// sample yet (or time did not advance since it), so value cannot be computed
+var ErrNotReady = errors.New("Formula needs more samples to compute rate")
+
+// Values maps variable names to their values
+type Values map[string]float64
+
+type function struct {
+	minArgs int
+	// maxArgs -1 means any number of arguments
+	maxArgs int
+	call    func(args []float64) float64
+}
+
+// functions available in formulas, rate is evaluated separately as it keeps state
+var functions = map[string]function{
+	"min": {minArgs: 1, maxArgs: -1, call: func(args []float64) float64 {
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Min(m, a)
+		}
+		return m
+	}},
+	"max": {minArgs: 1, maxArgs: -1, call: func(args []float64) float64 {
+		m := args[0]
+		for _, a := range args[1:] {
+			m = math.Max(m, a)
+		}
+		return m
+	}},
+	"abs": {minArgs: 1, maxArgs: 1, call: func(args []float64) float64 {
+		return math.Abs(args[0])
+	}},
+	// ratio is division which gives 0 when divisor is 0
+	"ratio": {minArgs: 2, maxArgs: 2, call: func(args []float64) float64 {
+		if args[1] == 0 {
+			return 0
+		}
+		return args[0] / args[1]
+	}},
+	// rate is change of argument per second since previous evaluation,
+	// it is negative when argument decreases
+	"rate": {minArgs: 1, maxArgs: 1},
+}
+
+func (fn function) check(t token) error {
+	if t.args < fn.minArgs || (fn.maxArgs >= 0 && t.args > fn.maxArgs) {
+		return fmt.Errorf("wrong number of arguments of %s at position %d: %d", t.text, t.pos, t.args)
+	}
+	return nil
+}
+
+type sample struct {
+	value float64
+	time  time.Time
+}
+
+// Eval evaluates formula with given variable values at time now, which is
+// used by rate. It returns ErrNotReady if rate has no previous sample.
+func (f *Formula) Eval(values Values, now time.Time) (float64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.previous == nil {
+		f.previous = map[int]sample{}
+	}
+
+	operands := stack.New[float64](0)
+	notReady := false
+	for i, t := range f.rpn {
+		switch t.kind {
+		case tokenNumber:
+			operands.Push(t.value)
+		case tokenVariable:
+			v, ok := values[t.text]
+			if !ok {
+				return 0, fmt.Errorf("Variable %q of formula %q has no value", t.text, f.expression)
+			}
+			operands.Push(v)
+		case tokenOperator:
+			if t.text == negation {
+				a, _ := operands.Pop()
+				operands.Push(-a)
+				continue
+			}
+			b, _ := operands.Pop()
+			a, _ := operands.Pop()
+			v, err := apply(t.text, a, b)
+			if err != nil {
+				return 0, fmt.Errorf("Cannot evaluate formula %q: %v", f.expression, err)
+			}
+			operands.Push(v)
+		case tokenFunction:
+			args := make([]float64, t.args)
+			for j := t.args - 1; j >= 0; j-- {
+				args[j], _ = operands.Pop()
+			}
+			if t.text != "rate" {
+				operands.Push(functions[t.text].call(args))
+				continue
+			}
+			// evaluate all rates, so each of them stores its sample
+			prev, ok := f.previous[i]
+			f.previous[i] = sample{value: args[0], time: now}
+			if !ok || !now.After(prev.time) {
+				notReady = true
+				operands.Push(0)
+				continue
+			}
+			operands.Push((args[0] - prev.value) / now.Sub(prev.time).Seconds())
+		}
+	}
+	if notReady {
+		return 0, ErrNotReady
+	}
+	v, _ := operands.Pop()
+	return v, nil
+}
+
+func apply(op string, a, b float64) (float64, error) {
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/", "%":
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		if op == "%" {
+			return math.Mod(a, b), nil
+		}
+		return a / b, nil
+	}
+	var result bool
+	switch op {
+	case "<":
+		result = a < b
+	case "<=":
+		result = a <= b
+	case ">":
+		result = a > b
+	case ">=":
+		result = a >= b
+	case "==":
+		result = a == b
+	case "!=":
+		result = a != b
+	}
+	if result {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// EvalMetrics evaluates formula with variables taking values of metrics
+// with bound namespaces. Time of the newest of those metrics is used for rate.
+func (f *Formula) EvalMetrics(metrics []plugin.MetricType) (float64, error) {
+	f.mutex.Lock()
+	variables := map[string][]string{}
+	bindings := map[string]string{}
+	for _, v := range f.variables {
+		ns, ok := f.bindings[v]
+		bindings[v] = ns
+		if !ok {
+			f.mutex.Unlock()
+			return 0, fmt.Errorf("Variable %q of formula %q is not bound to namespace", v, f.expression)
+		}
+		variables[ns] = append(variables[ns], v)
+	}
+	f.mutex.Unlock()
+
+	values := Values{}
+	var now time.Time
+	for _, m := range metrics {
+		ns := normalizeNamespace(m.Namespace().String())
+		names, ok := variables[ns]
+		if !ok {
+			continue
+		}
+		value, err := ToFloat(m.Data())
+		if err != nil {
+			return 0, fmt.Errorf("Metric %s: %v", ns, err)
+		}
+		for _, name := range names {
+			values[name] = value
+		}
+		if m.Timestamp().After(now) {
+			now = m.Timestamp()
+		}
+	}
+	for _, v := range f.variables {
+		if _, ok := values[v]; !ok {
+			return 0, fmt.Errorf("Metric %s for variable %q of formula %q not found", bindings[v], v, f.expression)
+		}
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return f.Eval(values, now)
+}
+
+// ToFloat converts numeric metric data to float64
+func ToFloat(data interface{}) (float64, error) {
+	switch v := data.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	}
+	return 0, fmt.Errorf("value %v of type %T is not a number", data, data)
+}
@@ -0,0 +1,332 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package formula evaluates arithmetic expressions over metric values,
+// so collectors can publish derived metrics like `used/total*100`.
+// Expressions are parsed with shunting-yard algorithm into reverse polish
+// notation, both using package stack.
+package formula
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/stack"
+)
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenVariable
+	tokenOperator
+	tokenFunction
+	tokenLeftParen
+	tokenRightParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value float64
+	// number of arguments of function
+	args int
+	pos  int
+}
+
+type operator struct {
+	precedence int
+	rightAssoc bool
+	unary      bool
+}
+
+// negation is unary minus, it has its own name to differ from subtraction
+const negation = "neg"
+
+var operators = map[string]operator{
+	"<":      {precedence: 1},
+	"<=":     {precedence: 1},
+	">":      {precedence: 1},
+	">=":     {precedence: 1},
+	"==":     {precedence: 1},
+	"!=":     {precedence: 1},
+	"+":      {precedence: 2},
+	"-":      {precedence: 2},
+	"*":      {precedence: 3},
+	"/":      {precedence: 3},
+	"%":      {precedence: 3},
+	negation: {precedence: 4, rightAssoc: true, unary: true},
+}
+
+// Formula is parsed expression. Variables are bound to metric namespaces
+// with Bind. Formula keeps state of rate functions between evaluations,
+// it is safe for concurrent use.
+type Formula struct {
+	expression string
+	rpn        []token
+	variables  []string
+	bindings   map[string]string
+
+	mutex sync.Mutex
+	// previous samples of rate calls, by position in rpn
+	previous map[int]sample
+}
+
+// Parse parses expression built of numbers, variables, operators
+// + - * / % < <= > >= == != (comparisons give 1 or 0), parentheses
+// and functions min, max, abs, ratio and rate.
+// Variable names consist of letters, digits, '_' and '.' and start with letter or '_'.
+func Parse(expression string) (*Formula, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid formula %q: %v", expression, err)
+	}
+	rpn, err := toRPN(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid formula %q: %v", expression, err)
+	}
+	f := &Formula{expression: expression, rpn: rpn, bindings: map[string]string{}}
+	seen := map[string]bool{}
+	for _, t := range rpn {
+		if t.kind == tokenVariable && !seen[t.text] {
+			seen[t.text] = true
+			f.variables = append(f.variables, t.text)
+		}
+	}
+	return f, nil
+}
+
+// String returns expression formula was parsed from
+func (f *Formula) String() string {
+	return f.expression
+}
+
+// Variables returns names of variables in order of their first use
+func (f *Formula) Variables() []string {
+	return append([]string{}, f.variables...)
+}
+
+// Bind binds variable to namespace of metric (e.g. "/intel/procfs/meminfo/used")
+// whose value is used by EvalMetrics
+func (f *Formula) Bind(variable, namespace string) error {
+	for _, v := range f.variables {
+		if v == variable {
+			f.mutex.Lock()
+			f.bindings[variable] = normalizeNamespace(namespace)
+			f.mutex.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("Formula %q has no variable %q", f.expression, variable)
+}
+
+// normalizeNamespace gives namespace with leading and without trailing "/",
+// as returned by core.Namespace.String, so bound namespaces match either way
+func normalizeNamespace(namespace string) string {
+	return "/" + strings.Trim(namespace, "/")
+}
+
+func tokenize(expression string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case unicode.IsDigit(r) || r == '.':
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' ||
+				runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, value: value, pos: start})
+			continue
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			kind := tokenVariable
+			if i < len(runes) && runes[i] == '(' {
+				if _, ok := functions[text]; !ok {
+					return nil, fmt.Errorf("unknown function %q at position %d", text, start)
+				}
+				kind = tokenFunction
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", pos: i})
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", pos: i})
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				if _, ok := operators[string(runes[i:i+2])]; ok {
+					text = string(runes[i : i+2])
+				}
+			}
+			if _, ok := operators[text]; !ok {
+				return nil, fmt.Errorf("unexpected %q at position %d", text, i)
+			}
+			i += len(text)
+			if text == "-" && expectsOperand(tokens) {
+				text = negation
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: text, pos: start})
+			continue
+		}
+		i++
+	}
+	return tokens, nil
+}
+
+// expectsOperand tells if next token must start an operand, so '-' is negation
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	switch tokens[len(tokens)-1].kind {
+	case tokenOperator, tokenLeftParen, tokenComma:
+		return true
+	}
+	return false
+}
+
+// toRPN converts tokens from infix to reverse polish notation
+// with shunting-yard algorithm, validating syntax on the way
+func toRPN(tokens []token) ([]token, error) {
+	rpn := []token{}
+	ops := stack.New[token](0)
+	// number of arguments of functions being parsed
+	args := stack.New[int](0)
+	operand := true
+
+	unexpected := func(t token) error {
+		return fmt.Errorf("unexpected %q at position %d", t.text, t.pos)
+	}
+
+	for _, t := range tokens {
+		switch t.kind {
+		case tokenNumber, tokenVariable:
+			if !operand {
+				return nil, unexpected(t)
+			}
+			rpn = append(rpn, t)
+			operand = false
+		case tokenFunction:
+			if !operand {
+				return nil, unexpected(t)
+			}
+			ops.Push(t)
+		case tokenOperator:
+			op := operators[t.text]
+			if op.unary != operand {
+				return nil, unexpected(t)
+			}
+			for {
+				top, ok := ops.Pick()
+				if !ok || top.kind != tokenOperator {
+					break
+				}
+				topOp := operators[top.text]
+				if topOp.precedence < op.precedence || (topOp.precedence == op.precedence && op.rightAssoc) {
+					break
+				}
+				ops.Pop()
+				rpn = append(rpn, top)
+			}
+			ops.Push(t)
+			operand = true
+		case tokenLeftParen:
+			if !operand {
+				return nil, unexpected(t)
+			}
+			if top, ok := ops.Pick(); ok && top.kind == tokenFunction {
+				args.Push(1)
+			}
+			ops.Push(t)
+		case tokenComma, tokenRightParen:
+			if operand {
+				return nil, unexpected(t)
+			}
+			for {
+				top, ok := ops.Pick()
+				if !ok {
+					return nil, unexpected(t)
+				}
+				if top.kind == tokenLeftParen {
+					break
+				}
+				ops.Pop()
+				rpn = append(rpn, top)
+			}
+			if t.kind == tokenComma {
+				n, ok := args.Pop()
+				if !ok || !isFunctionParen(ops) {
+					return nil, unexpected(t)
+				}
+				args.Push(n + 1)
+				operand = true
+				continue
+			}
+			ops.Pop()
+			if top, ok := ops.Pick(); ok && top.kind == tokenFunction {
+				ops.Pop()
+				top.args, _ = args.Pop()
+				if err := functions[top.text].check(top); err != nil {
+					return nil, err
+				}
+				rpn = append(rpn, top)
+			}
+		}
+	}
+	if operand {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	for !ops.Empty() {
+		top, _ := ops.Pop()
+		if top.kind == tokenLeftParen || top.kind == tokenFunction {
+			return nil, fmt.Errorf("unclosed parenthesis at position %d", top.pos)
+		}
+		rpn = append(rpn, top)
+	}
+	return rpn, nil
+}
+
+// isFunctionParen tells if parenthesis on top of ops opens function arguments
+func isFunctionParen(ops *stack.Stack[token]) bool {
+	paren, _ := ops.Peek(0)
+	fn, ok := ops.Peek(1)
+	return paren.kind == tokenLeftParen && ok && fn.kind == tokenFunction
+}
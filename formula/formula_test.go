@@ -0,0 +1,192 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package formula
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func eval(expression string, values Values) (float64, error) {
+	f, err := Parse(expression)
+	if err != nil {
+		return 0, err
+	}
+	return f.Eval(values, time.Now())
+}
+
+func TestParse(t *testing.T) {
+	Convey("Given valid expressions", t, func() {
+		Convey("Then they are evaluated with operator precedence", func() {
+			cases := map[string]float64{
+				"1 + 2 * 3":         7,
+				"(1 + 2) * 3":       9,
+				"10 - 4 - 3":        3,
+				"2 * -3":            -6,
+				"-(2 + 3)":          -5,
+				"--2":               2,
+				"7 % 4":             3,
+				"1.5e2 / 3":         50,
+				"1 + 2 > 2":         1,
+				"2 <= 1":            0,
+				"3 == 3 != 0":       1,
+				"min(3, 1, 2)":      1,
+				"max(3, min(8, 5))": 5,
+				"abs(-2.5)":         2.5,
+				"ratio(1, 0)":       0,
+				"ratio(1, 4) * 100": 25,
+			}
+			for expression, expected := range cases {
+				v, err := eval(expression, nil)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, expected)
+			}
+		})
+
+		Convey("Then variables are listed once in order of use", func() {
+			f, err := Parse("used / total * 100 + used_2.x - used")
+			So(err, ShouldBeNil)
+			So(f.Variables(), ShouldResemble, []string{"used", "total", "used_2.x"})
+			v, err := f.Eval(Values{"used": 1, "total": 4, "used_2.x": 5}, time.Now())
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, 29)
+		})
+	})
+
+	Convey("Given invalid expressions", t, func() {
+		Convey("Then they are rejected with position of error", func() {
+			cases := map[string]string{
+				"1 +":        "unexpected end of expression",
+				"1 2":        `unexpected "2" at position 2`,
+				"(1 + 2":     "unclosed parenthesis at position 0",
+				"1 + 2)":     `unexpected ")" at position 5`,
+				"1 # 2":      `unexpected "#" at position 2`,
+				"foo(1)":     `unknown function "foo" at position 0`,
+				"abs(1, 2)":  "wrong number of arguments of abs at position 0: 2",
+				"max()":      `unexpected ")" at position 4`,
+				"(1, 2)":     `unexpected "," at position 2`,
+				"1..2":       `invalid number "1..2" at position 0`,
+				"used total": `unexpected "total" at position 5`,
+			}
+			for expression, expected := range cases {
+				_, err := Parse(expression)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "Invalid formula "+quote(expression)+": "+expected)
+			}
+		})
+	})
+
+	Convey("Given evaluation errors", t, func() {
+		_, err := eval("a / b", Values{"a": 1, "b": 0})
+		So(err.Error(), ShouldEqual, `Cannot evaluate formula "a / b": division by zero`)
+		_, err = eval("a / b", Values{"a": 1})
+		So(err.Error(), ShouldEqual, `Variable "b" of formula "a / b" has no value`)
+	})
+}
+
+func quote(s string) string {
+	return `"` + s + `"`
+}
+
+func TestRate(t *testing.T) {
+	Convey("Given formula with rate", t, func() {
+		f, err := Parse("rate(bytes) * 8")
+		So(err, ShouldBeNil)
+		start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		Convey("Then first evaluation is not ready", func() {
+			_, err := f.Eval(Values{"bytes": 100}, start)
+			So(err, ShouldEqual, ErrNotReady)
+
+			Convey("Then rate is computed from previous sample", func() {
+				v, err := f.Eval(Values{"bytes": 300}, start.Add(2*time.Second))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 800)
+
+				Convey("Then decreasing value gives negative rate", func() {
+					v, err := f.Eval(Values{"bytes": 10}, start.Add(3*time.Second))
+					So(err, ShouldBeNil)
+					So(v, ShouldEqual, -2320)
+				})
+
+				Convey("Then sample at the same time is not ready", func() {
+					_, err := f.Eval(Values{"bytes": 400}, start.Add(2*time.Second))
+					So(err, ShouldEqual, ErrNotReady)
+				})
+			})
+		})
+	})
+}
+
+func TestEvalMetrics(t *testing.T) {
+	Convey("Given formula bound to namespaces", t, func() {
+		f, err := Parse("used / total * 100")
+		So(err, ShouldBeNil)
+		So(f.Bind("used", "/intel/mem/used"), ShouldBeNil)
+		So(f.Bind("free", "/intel/mem/free"), ShouldNotBeNil)
+
+		metric := func(data interface{}, ns ...string) plugin.MetricType {
+			return plugin.MetricType{Namespace_: core.NewNamespace(ns...), Data_: data, Timestamp_: time.Now()}
+		}
+		metrics := []plugin.MetricType{
+			metric(uint64(25), "intel", "mem", "used"),
+			metric(int32(200), "intel", "mem", "total"),
+		}
+
+		Convey("Then unbound variable is reported", func() {
+			_, err := f.EvalMetrics(metrics)
+			So(err.Error(), ShouldEqual, `Variable "total" of formula "used / total * 100" is not bound to namespace`)
+		})
+
+		Convey("When all variables are bound", func() {
+			So(f.Bind("total", "/intel/mem/total"), ShouldBeNil)
+
+			Convey("Then metric values are used", func() {
+				v, err := f.EvalMetrics(metrics)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 12.5)
+			})
+
+			Convey("Then missing metric is reported", func() {
+				_, err := f.EvalMetrics(metrics[:1])
+				So(err.Error(), ShouldEqual, `Metric /intel/mem/total for variable "total" of formula "used / total * 100" not found`)
+			})
+
+			Convey("Then non-numeric metric is reported", func() {
+				_, err := f.EvalMetrics([]plugin.MetricType{metric("x", "intel", "mem", "used"), metrics[1]})
+				So(err.Error(), ShouldEqual, "Metric /intel/mem/used: value x of type string is not a number")
+			})
+		})
+
+		Convey("When variable is bound without leading slash", func() {
+			So(f.Bind("total", "intel/mem/total/"), ShouldBeNil)
+
+			Convey("Then namespace of metric matches it", func() {
+				v, err := f.EvalMetrics(metrics)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, 12.5)
+			})
+		})
+	})
+}
@@ -0,0 +1,200 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/ns"
+)
+
+// Builder assembles plugin.MetricType from namespace template like
+// `/intel/mock/[host]/foo`, where `[host]` is dynamic element named host.
+// Errors are collected while building and returned by Build.
+type Builder struct {
+	namespace core.Namespace
+	filled    map[string]bool
+	tags      map[string]string
+	unit      string
+	desc      string
+	data      interface{}
+	timestamp time.Time
+	config    *cdata.ConfigDataNode
+	version   int
+	err       error
+}
+
+// NewMetric starts building metric with namespace given by template
+func NewMetric(template string) *Builder {
+	b := &Builder{filled: map[string]bool{}}
+	b.namespace, b.err = parseTemplate(template)
+	for _, nse := range b.namespace {
+		if nse.Name == "" {
+			b.validate(nse.Value)
+		}
+	}
+	return b
+}
+
+func (b *Builder) fail(format string, args ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (b *Builder) validate(part string) {
+	if err := ns.ValidateMetricNamespacePart(part); err != nil && b.err == nil {
+		b.err = err
+	}
+}
+
+// element returns index of dynamic element of given name
+func (b *Builder) element(name string) int {
+	for i, nse := range b.namespace {
+		if nse.Name == name {
+			return i
+		}
+	}
+	b.fail("Namespace %s has no dynamic element %s", b.namespace.String(), name)
+	return -1
+}
+
+// Dynamic sets value of dynamic element
+func (b *Builder) Dynamic(name, value string) *Builder {
+	if i := b.element(name); i >= 0 {
+		if value == "" {
+			b.fail("Value of dynamic element %s is empty", name)
+		}
+		b.validate(value)
+		b.namespace[i].Value = value
+		b.filled[name] = true
+	}
+	return b
+}
+
+// ElementDescription sets description of dynamic element
+func (b *Builder) ElementDescription(name, description string) *Builder {
+	if i := b.element(name); i >= 0 {
+		b.namespace[i].Description = description
+	}
+	return b
+}
+
+// Tag sets tag of metric
+func (b *Builder) Tag(key, value string) *Builder {
+	if b.tags == nil {
+		b.tags = map[string]string{}
+	}
+	b.tags[key] = value
+	return b
+}
+
+// Tags sets multiple tags of metric
+func (b *Builder) Tags(tags map[string]string) *Builder {
+	for k, v := range tags {
+		b.Tag(k, v)
+	}
+	return b
+}
+
+// Unit sets unit of metric
+func (b *Builder) Unit(unit string) *Builder {
+	b.unit = unit
+	return b
+}
+
+// Description sets description of metric
+func (b *Builder) Description(description string) *Builder {
+	b.desc = description
+	return b
+}
+
+// Data sets value of metric
+func (b *Builder) Data(data interface{}) *Builder {
+	b.data = data
+	return b
+}
+
+// Timestamp sets timestamp of metric, by default it is time of Build
+func (b *Builder) Timestamp(timestamp time.Time) *Builder {
+	b.timestamp = timestamp
+	return b
+}
+
+// Config sets config of metric, e.g. the config of requested metric
+func (b *Builder) Config(config *cdata.ConfigDataNode) *Builder {
+	b.config = config
+	return b
+}
+
+// Version sets version of metric
+func (b *Builder) Version(version int) *Builder {
+	b.version = version
+	return b
+}
+
+// Build returns metric for CollectMetrics. It returns error if namespace
+// is invalid or value of any dynamic element is not set.
+func (b *Builder) Build() (plugin.MetricType, error) {
+	m, err := b.BuildCatalog()
+	if err != nil {
+		return m, err
+	}
+	for _, nse := range b.namespace {
+		if nse.Name != "" && !b.filled[nse.Name] {
+			return plugin.MetricType{}, fmt.Errorf("Value of dynamic element %s is not set", nse.Name)
+		}
+	}
+	m.Timestamp_ = b.timestamp
+	if m.Timestamp_.IsZero() {
+		m.Timestamp_ = time.Now()
+	}
+	return m, nil
+}
+
+// BuildCatalog returns metric for GetMetricTypes, dynamic elements without
+// value are left as "*" and timestamp is not set
+func (b *Builder) BuildCatalog() (plugin.MetricType, error) {
+	if b.err != nil {
+		return plugin.MetricType{}, b.err
+	}
+	var tags map[string]string
+	if b.tags != nil {
+		// copy, so builder can be reused for other metrics
+		tags = make(map[string]string, len(b.tags))
+		for k, v := range b.tags {
+			tags[k] = v
+		}
+	}
+	return plugin.MetricType{
+		Namespace_:   append(core.Namespace{}, b.namespace...),
+		Tags_:        tags,
+		Unit_:        b.unit,
+		Description_: b.desc,
+		Data_:        b.data,
+		Config_:      b.config,
+		Version_:     b.version,
+	}, nil
+}
@@ -0,0 +1,108 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/core/cdata"
+)
+
+func TestBuilder(t *testing.T) {
+	Convey("Given metric builder with dynamic element", t, func() {
+		b := NewMetric("/intel/mock/[host]/load").
+			ElementDescription("host", "host name").
+			Description("load of host").
+			Unit("%").
+			Tag("rack", "r1").
+			Data(0.5)
+
+		Convey("When value of dynamic element is set", func() {
+			ts := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+			config := cdata.NewNode()
+			m, err := b.Dynamic("host", "host1").Timestamp(ts).Config(config).Version(2).Build()
+
+			Convey("Then metric is built", func() {
+				So(err, ShouldBeNil)
+				So(m.Namespace().String(), ShouldEqual, "/intel/mock/host1/load")
+				So(m.Namespace()[2].Name, ShouldEqual, "host")
+				So(m.Namespace()[2].Description, ShouldEqual, "host name")
+				So(m.Tags(), ShouldResemble, map[string]string{"rack": "r1"})
+				So(m.Unit(), ShouldEqual, "%")
+				So(m.Description(), ShouldEqual, "load of host")
+				So(m.Data(), ShouldEqual, 0.5)
+				So(m.Timestamp(), ShouldEqual, ts)
+				So(m.Config(), ShouldEqual, config)
+				So(m.Version(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When timestamp is not set", func() {
+			m, err := b.Dynamic("host", "host1").Build()
+
+			Convey("Then it defaults to now", func() {
+				So(err, ShouldBeNil)
+				So(time.Since(m.Timestamp()), ShouldBeLessThan, time.Minute)
+			})
+		})
+
+		Convey("When value of dynamic element is not set", func() {
+			_, err := b.Build()
+
+			Convey("Then metric is not built", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "Value of dynamic element host is not set")
+			})
+
+			Convey("Then catalog metric can be built", func() {
+				m, err := b.BuildCatalog()
+				So(err, ShouldBeNil)
+				So(m.Namespace().String(), ShouldEqual, "/intel/mock/*/load")
+				So(m.Timestamp().IsZero(), ShouldBeTrue)
+			})
+		})
+
+		Convey("When value contains not allowed chars", func() {
+			_, err := b.Dynamic("host", "host.example.com").Build()
+
+			Convey("Then metric is not built", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When unknown dynamic element is set", func() {
+			_, err := b.Dynamic("disk", "sda").Build()
+
+			Convey("Then metric is not built", func() {
+				So(err.Error(), ShouldEqual, "Namespace /intel/mock/*/load has no dynamic element disk")
+			})
+		})
+	})
+
+	Convey("Given invalid namespace template", t, func() {
+		_, err := NewMetric("/intel/mo ck/load").BuildCatalog()
+		So(err, ShouldNotBeNil)
+		_, err = NewMetric("/intel//load").BuildCatalog()
+		So(err, ShouldNotBeNil)
+	})
+}
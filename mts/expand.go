@@ -0,0 +1,121 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+// Expand matches namespaces of requested metrics against concrete namespaces
+// of available items (e.g. discovered disks) and returns metrics for each match,
+// in order of requests and available namespaces. Requested element matches
+// any value if it is "*" or "[name]"; such elements become dynamic elements
+// (with name and description taken from request) filled with concrete value.
+// Trailing "*" which is not a dynamic element matches one or more elements.
+// Config, tags, unit, description and version are carried over from request.
+// Namespace matched by more requests is returned once, at position of its
+// first match, built from the request with more named dynamic elements or,
+// if they have the same number of them, from the first request with config.
+func Expand(requested []plugin.MetricType, available []core.Namespace) []plugin.MetricType {
+	result := []plugin.MetricType{}
+	seen := map[string]int{}
+	for _, req := range requested {
+		for _, namespace := range available {
+			expanded, ok := expand(req.Namespace(), namespace)
+			if !ok {
+				continue
+			}
+			i, found := seen[expanded.String()]
+			if found && !preferred(expanded, req, result[i]) {
+				continue
+			}
+			m := req
+			m.Namespace_ = expanded
+			if req.Tags() != nil {
+				m.Tags_ = make(map[string]string, len(req.Tags()))
+				for k, v := range req.Tags() {
+					m.Tags_[k] = v
+				}
+			}
+			if found {
+				result[i] = m
+				continue
+			}
+			seen[expanded.String()] = len(result)
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// preferred checks if expanded namespace of request carries more than metric
+// which was already expanded to the same namespace
+func preferred(expanded core.Namespace, req, metric plugin.MetricType) bool {
+	_, names := expanded.IsDynamic()
+	_, existing := metric.Namespace().IsDynamic()
+	if len(names) != len(existing) {
+		return len(names) > len(existing)
+	}
+	return metric.Config() == nil && req.Config() != nil
+}
+
+// wildcard returns name of requested element matching any value,
+// it is "" for "*" without name
+func wildcard(nse core.NamespaceElement) (string, bool) {
+	if nse.Value == "*" {
+		return nse.Name, true
+	}
+	if strings.HasPrefix(nse.Value, "[") && strings.HasSuffix(nse.Value, "]") && len(nse.Value) > 2 {
+		return nse.Value[1 : len(nse.Value)-1], true
+	}
+	return "", false
+}
+
+// expand builds concrete namespace if it matches requested one
+func expand(requested, concrete core.Namespace) (core.Namespace, bool) {
+	expanded := core.Namespace{}
+	for i, nse := range requested {
+		if i >= len(concrete) {
+			return nil, false
+		}
+		name, isWildcard := wildcard(nse)
+		switch {
+		case !isWildcard:
+			if nse.Value != concrete[i].Value {
+				return nil, false
+			}
+			expanded = append(expanded, concrete[i])
+		case name == "" && i == len(requested)-1:
+			// trailing wildcard matches the rest of namespace
+			return append(expanded, concrete[i:]...), true
+		case name == "":
+			expanded = append(expanded, concrete[i])
+		default:
+			expanded = append(expanded, core.NamespaceElement{Value: concrete[i].Value, Name: name, Description: nse.Description})
+		}
+	}
+	if len(requested) != len(concrete) {
+		return nil, false
+	}
+	return expanded, true
+}
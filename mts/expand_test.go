@@ -0,0 +1,130 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/cdata"
+)
+
+func TestExpand(t *testing.T) {
+	Convey("Given available namespaces of discovered disks", t, func() {
+		available := []core.Namespace{
+			core.NewNamespace("intel", "disk", "sda", "reads"),
+			core.NewNamespace("intel", "disk", "sda", "writes"),
+			core.NewNamespace("intel", "disk", "sdb", "reads"),
+			core.NewNamespace("intel", "mem", "free"),
+		}
+		config := cdata.NewNode()
+
+		Convey("When metric with dynamic element is requested", func() {
+			requested := []plugin.MetricType{
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "disk").AddDynamicElement("disk", "disk name").AddStaticElement("reads"),
+					Config_:    config,
+					Tags_:      map[string]string{"rack": "r1"},
+					Unit_:      "ops",
+				},
+			}
+			metrics := Expand(requested, available)
+
+			Convey("Then concrete metrics with dynamic values filled in are returned", func() {
+				So(len(metrics), ShouldEqual, 2)
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/disk/sda/reads")
+				So(metrics[1].Namespace().String(), ShouldEqual, "/intel/disk/sdb/reads")
+				So(metrics[0].Namespace()[2], ShouldResemble, core.NamespaceElement{Value: "sda", Name: "disk", Description: "disk name"})
+				isDynamic, _ := metrics[1].Namespace().IsDynamic()
+				So(isDynamic, ShouldBeTrue)
+			})
+
+			Convey("Then config and other fields are carried over", func() {
+				So(metrics[0].Config(), ShouldEqual, config)
+				So(metrics[0].Unit(), ShouldEqual, "ops")
+				So(metrics[0].Tags(), ShouldResemble, map[string]string{"rack": "r1"})
+				metrics[0].Tags()["rack"] = "r2"
+				So(requested[0].Tags()["rack"], ShouldEqual, "r1")
+			})
+		})
+
+		Convey("When metrics with wildcards and named elements are requested", func() {
+			requested := []plugin.MetricType{
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "disk", "*", "writes")},
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "disk", "[device]", "writes"), Config_: config},
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "*")},
+			}
+			metrics := Expand(requested, available)
+
+			Convey("Then each concrete namespace is returned once", func() {
+				namespaces := []string{}
+				for _, m := range metrics {
+					namespaces = append(namespaces, m.Namespace().String())
+				}
+				So(namespaces, ShouldResemble, []string{
+					"/intel/disk/sda/writes",
+					"/intel/disk/sda/reads",
+					"/intel/disk/sdb/reads",
+					"/intel/mem/free",
+				})
+			})
+
+			Convey("Then duplicate is built from request with named element", func() {
+				So(metrics[0].Namespace()[2].Name, ShouldEqual, "device")
+				So(metrics[0].Config(), ShouldEqual, config)
+				isDynamic, _ := metrics[1].Namespace().IsDynamic()
+				So(isDynamic, ShouldBeFalse)
+			})
+
+			Convey("Then named element becomes dynamic", func() {
+				metrics := Expand(requested[1:2], available)
+				So(len(metrics), ShouldEqual, 1)
+				So(metrics[0].Namespace()[2].Name, ShouldEqual, "device")
+			})
+		})
+
+		Convey("When the same namespace is requested without and with config", func() {
+			requested := []plugin.MetricType{
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "mem", "*")},
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "mem", "free"), Config_: config},
+			}
+			metrics := Expand(requested, available)
+
+			Convey("Then config of the latter is kept", func() {
+				So(len(metrics), ShouldEqual, 1)
+				So(metrics[0].Config(), ShouldEqual, config)
+			})
+		})
+
+		Convey("When nothing matches", func() {
+			requested := []plugin.MetricType{
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "net", "*")},
+				plugin.MetricType{Namespace_: core.NewNamespace("intel", "mem", "free", "x")},
+			}
+
+			Convey("Then no metrics are returned", func() {
+				So(Expand(requested, available), ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,266 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/config"
+)
+
+// Filter selects metrics with include and exclude rules. Metric passes
+// if it matches any include rule (or there are no include rules) and
+// matches no exclude rule.
+type Filter struct {
+	include []filterRule
+	exclude []filterRule
+}
+
+type filterRule struct {
+	elements []elementMatcher
+	tags     []tagPredicate
+}
+
+type elementMatcher struct {
+	// any number of elements, for "**"
+	any     bool
+	glob    string
+	pattern *regexp.Regexp
+}
+
+type tagPredicate struct {
+	key    string
+	op     string
+	value  string
+	regexp *regexp.Regexp
+}
+
+var tagPredicateRegexp = regexp.MustCompile(`^([A-Za-z_][\w.\-]*)\s*(=~|!~|!=|=)\s*("(?:[^"\\]|\\.)*")`)
+
+// ParseFilter parses rules separated by new lines or ';', '#' starts a comment.
+// Each rule is `include` or `exclude` followed by namespace pattern and
+// optional tag predicates, e.g.
+//
+//	include /intel/*/disk/** host=~"web.*"
+//	exclude /intel/*/disk/~loop[0-9]+/** env!="prod"
+//
+// In namespace pattern `*` matches any element, `**` any number of elements,
+// elements starting with `~` are regular expressions matching the whole element
+// and other elements are globs (see path.Match). Tag predicates compare tag
+// with `=`, `!=` or with regular expression matching the whole value with
+// `=~`, `!~`. Missing tag has empty value.
+func ParseFilter(rules string) (*Filter, error) {
+	f := &Filter{}
+	for i, line := range splitRules(rules) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Invalid filter rule #%d %q: namespace pattern is missing", i, line)
+		}
+		rule, err := parseRule(fields[1], strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[len(fields[0]):]), fields[1])))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid filter rule #%d %q: %v", i, line, err)
+		}
+		switch fields[0] {
+		case "include":
+			f.include = append(f.include, rule)
+		case "exclude":
+			f.exclude = append(f.exclude, rule)
+		default:
+			return nil, fmt.Errorf("Invalid filter rule #%d %q: rule must start with include or exclude", i, line)
+		}
+	}
+	return f, nil
+}
+
+// FilterFromConfig parses filter rules given as string item `name` of plugin
+// global config or metric config. Missing item gives filter passing all metrics.
+func FilterFromConfig(cfg interface{}, name string) (*Filter, error) {
+	item, err := config.GetConfigItem(cfg, name)
+	if config.IsNotFound(err) {
+		return &Filter{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rules, ok := item.(string)
+	if !ok {
+		return nil, fmt.Errorf("Config item %v must be a string with filter rules, got %T", name, item)
+	}
+	return ParseFilter(rules)
+}
+
+// splitRules splits rules on new lines and ';' outside of quotes
+func splitRules(rules string) []string {
+	result := []string{}
+	start := 0
+	quoted, escaped := false, false
+	for i, r := range rules {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case (r == '\n' || r == ';') && !quoted:
+			result = append(result, rules[start:i])
+			start = i + 1
+		}
+	}
+	return append(result, rules[start:])
+}
+
+func parseRule(pattern, predicates string) (filterRule, error) {
+	rule := filterRule{}
+	if !strings.HasPrefix(pattern, "/") {
+		return rule, fmt.Errorf("namespace pattern must start with /")
+	}
+	for _, part := range strings.Split(pattern[1:], "/") {
+		switch {
+		case part == "":
+			return rule, fmt.Errorf("namespace pattern contains empty element")
+		case part == "**":
+			rule.elements = append(rule.elements, elementMatcher{any: true})
+		case strings.HasPrefix(part, "~"):
+			re, err := regexp.Compile("^(?:" + part[1:] + ")$")
+			if err != nil {
+				return rule, err
+			}
+			rule.elements = append(rule.elements, elementMatcher{pattern: re})
+		default:
+			if _, err := path.Match(part, ""); err != nil {
+				return rule, fmt.Errorf("invalid glob %q: %v", part, err)
+			}
+			rule.elements = append(rule.elements, elementMatcher{glob: part})
+		}
+	}
+	for predicates != "" {
+		m := tagPredicateRegexp.FindStringSubmatch(predicates)
+		if m == nil {
+			return rule, fmt.Errorf("invalid tag predicate %q", predicates)
+		}
+		value, err := strconv.Unquote(m[3])
+		if err != nil {
+			return rule, fmt.Errorf("invalid tag predicate %q: %v", m[0], err)
+		}
+		p := tagPredicate{key: m[1], op: m[2], value: value}
+		if p.op == "=~" || p.op == "!~" {
+			if p.regexp, err = regexp.Compile("^(?:" + value + ")$"); err != nil {
+				return rule, err
+			}
+		}
+		rule.tags = append(rule.tags, p)
+		predicates = strings.TrimSpace(predicates[len(m[0]):])
+	}
+	return rule, nil
+}
+
+// Match tells if metric passes filter
+func (f *Filter) Match(metric plugin.MetricType) bool {
+	values := metric.Namespace().Strings()
+	included := len(f.include) == 0
+	for _, rule := range f.include {
+		if rule.match(values, metric.Tags()) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, rule := range f.exclude {
+		if rule.match(values, metric.Tags()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns metrics which pass filter
+func (f *Filter) Apply(metrics []plugin.MetricType) []plugin.MetricType {
+	result := []plugin.MetricType{}
+	for _, m := range metrics {
+		if f.Match(m) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func (r filterRule) match(namespace []string, tags map[string]string) bool {
+	if !matchElements(r.elements, namespace) {
+		return false
+	}
+	for _, p := range r.tags {
+		if !p.match(tags[p.key]) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchElements(elements []elementMatcher, namespace []string) bool {
+	if len(elements) == 0 {
+		return len(namespace) == 0
+	}
+	e := elements[0]
+	if e.any {
+		for i := 0; i <= len(namespace); i++ {
+			if matchElements(elements[1:], namespace[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(namespace) == 0 || !e.match(namespace[0]) {
+		return false
+	}
+	return matchElements(elements[1:], namespace[1:])
+}
+
+func (e elementMatcher) match(value string) bool {
+	if e.pattern != nil {
+		return e.pattern.MatchString(value)
+	}
+	matched, _ := path.Match(e.glob, value)
+	return matched
+}
+
+func (p tagPredicate) match(value string) bool {
+	switch p.op {
+	case "=":
+		return value == p.value
+	case "!=":
+		return value != p.value
+	case "=~":
+		return p.regexp.MatchString(value)
+	}
+	return !p.regexp.MatchString(value)
+}
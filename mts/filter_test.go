@@ -0,0 +1,133 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+	"github.com/intelsdi-x/snap/core/ctypes"
+)
+
+func metricWithTags(tags map[string]string, elements ...string) plugin.MetricType {
+	return plugin.MetricType{Namespace_: core.NewNamespace(elements...), Tags_: tags}
+}
+
+func namespaces(metrics []plugin.MetricType) []string {
+	result := []string{}
+	for _, m := range metrics {
+		result = append(result, m.Namespace().String())
+	}
+	return result
+}
+
+func TestFilter(t *testing.T) {
+	metrics := []plugin.MetricType{
+		metricWithTags(map[string]string{"host": "web1"}, "intel", "linux", "disk", "sda", "reads"),
+		metricWithTags(map[string]string{"host": "db1"}, "intel", "linux", "disk", "sdb", "reads"),
+		metricWithTags(map[string]string{"host": "web2"}, "intel", "linux", "disk", "loop0", "reads"),
+		metricWithTags(map[string]string{"host": "web1"}, "intel", "linux", "disk"),
+		metricWithTags(nil, "intel", "linux", "mem", "free"),
+		metricWithTags(nil, "intel", "psutil", "load", "load1"),
+	}
+
+	Convey("Given filter with globs, regular expressions and tag predicates", t, func() {
+		f, err := ParseFilter(`
+			# disks of web servers
+			include /intel/*/disk/** host=~"web.*"
+			exclude /intel/*/disk/~loop[0-9]+/**
+			include /intel/linux/mem/fr?e; include /intel/psutil/load/* env!="test"
+		`)
+		So(err, ShouldBeNil)
+
+		Convey("Then only matching metrics pass", func() {
+			So(namespaces(f.Apply(metrics)), ShouldResemble, []string{
+				"/intel/linux/disk/sda/reads",
+				"/intel/linux/disk",
+				"/intel/linux/mem/free",
+				"/intel/psutil/load/load1",
+			})
+		})
+	})
+
+	Convey("Given filter with exclude rules only", t, func() {
+		f, err := ParseFilter(`exclude /intel/** host="db1"; exclude /intel/linux/mem/**`)
+		So(err, ShouldBeNil)
+
+		Convey("Then other metrics pass", func() {
+			So(len(f.Apply(metrics)), ShouldEqual, 4)
+			So(f.Match(metrics[1]), ShouldBeFalse)
+			So(f.Match(metrics[4]), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given tag predicates with quotes and separators", t, func() {
+		f, err := ParseFilter(`include /** host!~"db;\"1\"" host!=""`)
+		So(err, ShouldBeNil)
+
+		Convey("Then quoted values are unescaped and missing tags are empty", func() {
+			So(len(f.Apply(metrics)), ShouldEqual, 4)
+			So(f.Match(metricWithTags(map[string]string{"host": `db;"1"`}, "intel")), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given invalid rules", t, func() {
+		cases := map[string]string{
+			"allow /intel/**":       `Invalid filter rule #0 "allow /intel/**": rule must start with include or exclude`,
+			"include":               `Invalid filter rule #0 "include": namespace pattern is missing`,
+			"include intel/**":      `Invalid filter rule #0 "include intel/**": namespace pattern must start with /`,
+			"\ninclude /intel//x":   `Invalid filter rule #1 "include /intel//x": namespace pattern contains empty element`,
+			"include /intel/~(":     "Invalid filter rule #0 \"include /intel/~(\": error parsing regexp: missing closing ): `^(?:()$`",
+			"include /intel/[":      `Invalid filter rule #0 "include /intel/[": invalid glob "[": syntax error in pattern`,
+			"include /intel host=x": `Invalid filter rule #0 "include /intel host=x": invalid tag predicate "host=x"`,
+			`include /intel h=~"("`: "Invalid filter rule #0 \"include /intel h=~\\\"(\\\"\": error parsing regexp: missing closing ): `^(?:()$`",
+		}
+		for rules, expected := range cases {
+			_, err := ParseFilter(rules)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, expected)
+		}
+	})
+
+	Convey("Given filter rules in config", t, func() {
+		cfg := plugin.NewPluginConfigType()
+		cfg.AddItem("filter", ctypes.ConfigValueStr{Value: "include /intel/linux/**"})
+
+		Convey("Then filter is created from config item", func() {
+			f, err := FilterFromConfig(cfg, "filter")
+			So(err, ShouldBeNil)
+			So(len(f.Apply(metrics)), ShouldEqual, 5)
+		})
+
+		Convey("Then missing item gives filter passing all metrics", func() {
+			f, err := FilterFromConfig(cfg, "other")
+			So(err, ShouldBeNil)
+			So(len(f.Apply(metrics)), ShouldEqual, 6)
+		})
+
+		Convey("Then unsupported config is reported", func() {
+			_, err := FilterFromConfig("filter", "filter")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
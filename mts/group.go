@@ -0,0 +1,155 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// Group is a set of metrics sharing the same key
+type Group struct {
+	Key     string
+	Metrics []plugin.MetricType
+}
+
+// groupBy groups metrics by key, groups are sorted by key and keep order of metrics
+func groupBy(metrics []plugin.MetricType, key func(plugin.MetricType) string) []Group {
+	index := map[string]int{}
+	groups := []Group{}
+	for _, m := range metrics {
+		k := key(m)
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, Group{Key: k})
+		}
+		groups[i].Metrics = append(groups[i].Metrics, m)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups
+}
+
+// GroupByPrefix groups metrics by first depth elements of namespace,
+// e.g. "/intel/disk" for depth 2. Dynamic elements are left out like
+// in ConvertDynamicElements, so they do not split groups.
+func GroupByPrefix(metrics []plugin.MetricType, depth int) []Group {
+	return groupBy(metrics, func(m plugin.MetricType) string {
+		static, _ := splitSeries(m)
+		if len(static) > depth {
+			static = static[:depth]
+		}
+		return "/" + strings.Join(static, "/")
+	})
+}
+
+// GroupByTag groups metrics by value of tag, dynamic element of the same
+// name is treated as tag like in ConvertDynamicElements. Metrics without
+// the tag form group with empty key.
+func GroupByTag(metrics []plugin.MetricType, tag string) []Group {
+	return groupBy(metrics, func(m plugin.MetricType) string {
+		_, tags := splitSeries(m)
+		return tags[tag]
+	})
+}
+
+// Dedup returns metrics without duplicates, i.e. metrics of the same series
+// (see SeriesKey) and timestamp as earlier metric
+func Dedup(metrics []plugin.MetricType) []plugin.MetricType {
+	type point struct {
+		series string
+		time   int64
+	}
+	seen := map[point]bool{}
+	result := []plugin.MetricType{}
+	for _, m := range metrics {
+		p := point{SeriesKey(m), m.Timestamp().UnixNano()}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		result = append(result, m)
+	}
+	return result
+}
+
+// Sort sorts metrics in place by series key and timestamp, order of metrics
+// with equal series and timestamp is kept
+func Sort(metrics []plugin.MetricType) {
+	keys := make([]string, len(metrics))
+	for i, m := range metrics {
+		keys[i] = SeriesKey(m)
+	}
+	sort.Stable(bySeries{metrics, keys})
+}
+
+type bySeries struct {
+	metrics []plugin.MetricType
+	keys    []string
+}
+
+func (s bySeries) Len() int {
+	return len(s.metrics)
+}
+
+func (s bySeries) Less(i, j int) bool {
+	if s.keys[i] != s.keys[j] {
+		return s.keys[i] < s.keys[j]
+	}
+	return s.metrics[i].Timestamp().Before(s.metrics[j].Timestamp())
+}
+
+func (s bySeries) Swap(i, j int) {
+	s.metrics[i], s.metrics[j] = s.metrics[j], s.metrics[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
+
+// Batches splits metrics into batches of at most maxCount metrics,
+// maxCount lower than 1 gives one batch
+func Batches(metrics []plugin.MetricType, maxCount int) [][]plugin.MetricType {
+	return BatchesBySize(metrics, maxCount, func(plugin.MetricType) int { return 1 })
+}
+
+// BatchesBySize splits metrics into batches whose total size, as returned by
+// sizeOf (e.g. length of serialized metric), does not exceed maxSize.
+// Metric bigger than maxSize forms batch on its own. maxSize lower than 1
+// gives one batch.
+func BatchesBySize(metrics []plugin.MetricType, maxSize int, sizeOf func(plugin.MetricType) int) [][]plugin.MetricType {
+	batches := [][]plugin.MetricType{}
+	if len(metrics) == 0 {
+		return batches
+	}
+	if maxSize < 1 {
+		return append(batches, metrics)
+	}
+	start, size := 0, 0
+	for i, m := range metrics {
+		s := sizeOf(m)
+		if i > start && size+s > maxSize {
+			batches = append(batches, metrics[start:i:i])
+			start, size = i, 0
+		}
+		size += s
+	}
+	return append(batches, metrics[start:])
+}
@@ -0,0 +1,145 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestGroup(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	disk := func(name, metric string, seconds int, host string) plugin.MetricType {
+		m := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "disk").AddDynamicElement("disk", "disk name").AddStaticElement(metric),
+			Timestamp_: start.Add(time.Duration(seconds) * time.Second),
+			Tags_:      map[string]string{"host": host},
+		}
+		m.Namespace()[2].Value = name
+		return m
+	}
+	metrics := []plugin.MetricType{
+		disk("sdb", "reads", 0, "h1"),
+		disk("sda", "reads", 1, "h2"),
+		{Namespace_: core.NewNamespace("intel", "mem", "free"), Timestamp_: start},
+		disk("sda", "reads", 0, "h2"),
+		disk("sda", "reads", 1, "h2"),
+	}
+
+	Convey("Given metrics of disks and memory", t, func() {
+		Convey("Then they are grouped by namespace prefix", func() {
+			groups := GroupByPrefix(metrics, 2)
+			So(len(groups), ShouldEqual, 2)
+			So(groups[0].Key, ShouldEqual, "/intel/disk")
+			So(len(groups[0].Metrics), ShouldEqual, 4)
+			So(groups[1].Key, ShouldEqual, "/intel/mem")
+
+			groups = GroupByPrefix(metrics, 10)
+			So(groups[0].Key, ShouldEqual, "/intel/disk/reads")
+		})
+
+		Convey("Then they are grouped by tag or dynamic element", func() {
+			groups := GroupByTag(metrics, "disk")
+			So(len(groups), ShouldEqual, 3)
+			So(groups[0].Key, ShouldEqual, "")
+			So(groups[1].Key, ShouldEqual, "sda")
+			So(len(groups[1].Metrics), ShouldEqual, 3)
+			So(groups[1].Metrics[0].Timestamp(), ShouldEqual, start.Add(time.Second))
+
+			groups = GroupByTag(metrics, "host")
+			So(groups[2].Key, ShouldEqual, "h2")
+		})
+
+		Convey("Then duplicates of series and timestamp are dropped", func() {
+			deduped := Dedup(metrics)
+			So(len(deduped), ShouldEqual, 4)
+			So(deduped[3].Timestamp(), ShouldEqual, start)
+		})
+
+		Convey("Then metrics of series with colliding tag values are kept", func() {
+			a := plugin.MetricType{Namespace_: core.NewNamespace("x"), Timestamp_: start, Tags_: map[string]string{"a": "1,b=2"}}
+			b := plugin.MetricType{Namespace_: core.NewNamespace("x"), Timestamp_: start, Tags_: map[string]string{"a": "1", "b": "2"}}
+			So(len(Dedup([]plugin.MetricType{a, b})), ShouldEqual, 2)
+			sorted := []plugin.MetricType{a, b}
+			Sort(sorted)
+			So(sorted[0].Tags(), ShouldResemble, b.Tags())
+		})
+
+		Convey("Then converted metrics are duplicates of dynamic ones", func() {
+			converted := []plugin.MetricType{disk("sda", "reads", 0, "h2")}
+			ConvertDynamicElements(converted)
+			So(len(Dedup(append(converted, metrics[3]))), ShouldEqual, 1)
+		})
+
+		Convey("Then they are sorted by series and timestamp", func() {
+			sorted := append([]plugin.MetricType{}, metrics...)
+			Sort(sorted)
+			keys := []string{}
+			for _, m := range sorted {
+				keys = append(keys, SeriesKey(m)+"@"+m.Timestamp().Format("05"))
+			}
+			So(keys, ShouldResemble, []string{
+				"/intel/disk/reads{disk=sda,host=h2}@00",
+				"/intel/disk/reads{disk=sda,host=h2}@01",
+				"/intel/disk/reads{disk=sda,host=h2}@01",
+				"/intel/disk/reads{disk=sdb,host=h1}@00",
+				"/intel/mem/free{}@00",
+			})
+		})
+	})
+
+	Convey("Given metrics to be sent in batches", t, func() {
+		Convey("Then they are split by count", func() {
+			batches := Batches(metrics, 2)
+			So(len(batches), ShouldEqual, 3)
+			So(len(batches[0]), ShouldEqual, 2)
+			So(len(batches[2]), ShouldEqual, 1)
+			So(len(Batches(metrics, 0)), ShouldEqual, 1)
+			So(Batches(nil, 2), ShouldBeEmpty)
+		})
+
+		Convey("Then they are split by size", func() {
+			sizes := map[string]int{"/intel/mem/free": 50}
+			sizeOf := func(m plugin.MetricType) int {
+				if s, ok := sizes[m.Namespace().String()]; ok {
+					return s
+				}
+				return 10
+			}
+			batches := BatchesBySize(metrics, 25, sizeOf)
+			counts := []int{}
+			for _, b := range batches {
+				counts = append(counts, len(b))
+			}
+			So(counts, ShouldResemble, []int{2, 1, 2})
+		})
+
+		Convey("Then appending to batch does not overwrite next one", func() {
+			batches := Batches(append([]plugin.MetricType{}, metrics...), 2)
+			batches[0] = append(batches[0], metrics[0])
+			So(batches[1][0].Namespace().String(), ShouldEqual, "/intel/mem/free")
+		})
+	})
+}
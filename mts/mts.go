@@ -20,8 +20,13 @@ limitations under the License.
 package mts
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/intelsdi-x/snap/control/plugin"
 	"github.com/intelsdi-x/snap/core"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/ns"
 )
 
 // ConvertDynamicElements removes dynamic `NamespaceElements` from `core.Namespace`
@@ -30,24 +35,233 @@ import (
 // where `hostN` is dynamic `NamespaceElement` (has Name) and one wants to publish them
 // as `/intel/mock/foo` with additional tags as like host_name: hostN
 func ConvertDynamicElements(metrics []plugin.MetricType) {
+	ConvertDynamicElementsWithOptions(metrics)
+}
+
+// ConflictPolicy tells what to do when tag created from dynamic element
+// already exists
+type ConflictPolicy int
+
+const (
+	// Overwrite replaces existing tag with value of dynamic element
+	Overwrite ConflictPolicy = iota
+	// KeepExisting keeps existing tag, value of dynamic element is dropped
+	KeepExisting
+	// FailOnConflict makes conversion return error
+	FailOnConflict
+)
+
+type convertOptions struct {
+	rename   map[string]string
+	prefix   string
+	conflict ConflictPolicy
+	only     map[string]bool
+	copy     bool
+}
+
+// ConvertOption configures ConvertDynamicElementsWithOptions
+type ConvertOption func(*convertOptions)
+
+// RenameTags sets tag keys for dynamic elements of given names,
+// elements not in the map use their name
+func RenameTags(rename map[string]string) ConvertOption {
+	return func(o *convertOptions) {
+		o.rename = rename
+	}
+}
+
+// TagPrefix sets prefix added to keys of generated tags
+func TagPrefix(prefix string) ConvertOption {
+	return func(o *convertOptions) {
+		o.prefix = prefix
+	}
+}
+
+// OnConflict sets policy for tags which already exist, default is Overwrite
+func OnConflict(policy ConflictPolicy) ConvertOption {
+	return func(o *convertOptions) {
+		o.conflict = policy
+	}
+}
+
+// OnlyElements limits conversion to dynamic elements of given names,
+// other dynamic elements stay in namespace
+func OnlyElements(names ...string) ConvertOption {
+	return func(o *convertOptions) {
+		o.only = map[string]bool{}
+		for _, name := range names {
+			o.only[name] = true
+		}
+	}
+}
+
+// ReturnCopy makes conversion return new metrics and leave given ones unchanged,
+// namespaces and tags of returned metrics are not shared with given ones
+func ReturnCopy() ConvertOption {
+	return func(o *convertOptions) {
+		o.copy = true
+	}
+}
+
+// ConvertDynamicElementsWithOptions turns dynamic `NamespaceElements` into tags
+// like ConvertDynamicElements, with tag keys, conflicts and selection of elements
+// controlled by options. By default metrics are changed in place and returned.
+// With FailOnConflict policy no metric is changed if any conflict is found.
+func ConvertDynamicElementsWithOptions(metrics []plugin.MetricType, options ...ConvertOption) ([]plugin.MetricType, error) {
+	opts := convertOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	namespaces := make([]core.Namespace, len(metrics))
+	tags := make([]map[string]string, len(metrics))
 	for j, metric := range metrics {
-		if isDynamic, indexes := metric.Namespace().IsDynamic(); isDynamic {
-			static := core.Namespace{}
-			tags := metric.Tags()
-			if tags == nil {
-				tags = map[string]string{}
+		namespaces[j], tags[j] = metric.Namespace(), metric.Tags()
+		isDynamic, indexes := metric.Namespace().IsDynamic()
+		if !isDynamic {
+			continue
+		}
+		static := core.Namespace{}
+		converted := map[string]string{}
+		for k, v := range metric.Tags() {
+			converted[k] = v
+		}
+		for i, nse := range metric.Namespace() {
+			if !contains(indexes, i) || (opts.only != nil && !opts.only[nse.Name]) {
+				static = append(static, nse)
+				continue
+			}
+			key := nse.Name
+			if renamed, ok := opts.rename[key]; ok {
+				key = renamed
 			}
-			for i, nse := range metric.Namespace() {
-				if contains(indexes, i) {
-					tags[nse.Name] = nse.Value
-				} else {
-					static = append(static, nse)
+			key = opts.prefix + key
+			if _, exists := converted[key]; exists {
+				switch opts.conflict {
+				case KeepExisting:
+					continue
+				case FailOnConflict:
+					return nil, fmt.Errorf("Tag %s of metric %s already exists", key, metric.Namespace().String())
 				}
 			}
-			metrics[j].Namespace_ = static
-			metrics[j].Tags_ = tags
+			converted[key] = nse.Value
 		}
+		namespaces[j], tags[j] = static, converted
+	}
+
+	result := metrics
+	if opts.copy {
+		result = make([]plugin.MetricType, len(metrics))
+		copy(result, metrics)
+	}
+	for j := range result {
+		if opts.copy {
+			namespaces[j] = append(core.Namespace{}, namespaces[j]...)
+			tags[j] = copyTags(tags[j])
+		}
+		result[j].Namespace_ = namespaces[j]
+		result[j].Tags_ = tags[j]
+	}
+	return result, nil
+}
+
+// RestoreDynamicElements is the inverse of ConvertDynamicElements, it turns tags
+// back into dynamic `NamespaceElements` according to namespace templates like
+// `/intel/mock/[host]/foo`, where `[host]` is dynamic element named host.
+// Metric `/intel/mock/foo` with tag host: host1 becomes `/intel/mock/host1/foo`
+// and the tag is removed. The first template whose static elements equal
+// namespace of metric and whose dynamic elements are all present in tags is
+// used. Other metrics, including ones which already have dynamic elements,
+// are left unchanged. It returns error if a template is invalid.
+// Use RestoreDynamicNamespaces to keep descriptions of dynamic elements.
+func RestoreDynamicElements(metrics []plugin.MetricType, templates ...string) error {
+	parsed := make([]core.Namespace, len(templates))
+	for i, t := range templates {
+		namespace, err := parseTemplate(t)
+		if err != nil {
+			return err
+		}
+		parsed[i] = namespace
+	}
+	RestoreDynamicNamespaces(metrics, parsed...)
+	return nil
+}
+
+// RestoreDynamicNamespaces works like RestoreDynamicElements with templates
+// given as namespaces, e.g. from metric catalog, where dynamic elements are
+// the ones with Name. Restored dynamic elements are copies of template
+// elements (including Description) with values taken from tags.
+// Restored metrics get new tag maps, so maps shared with other metrics
+// are not changed.
+func RestoreDynamicNamespaces(metrics []plugin.MetricType, templates ...core.Namespace) {
+	for j, metric := range metrics {
+		if isDynamic, _ := metric.Namespace().IsDynamic(); isDynamic {
+			continue
+		}
+		for _, template := range templates {
+			if restored, ok := restore(metric.Namespace(), metric.Tags(), template); ok {
+				tags := copyTags(metric.Tags())
+				for _, nse := range restored {
+					if nse.Name != "" {
+						delete(tags, nse.Name)
+					}
+				}
+				metrics[j].Namespace_ = restored
+				metrics[j].Tags_ = tags
+				break
+			}
+		}
+	}
+}
+
+// parseTemplate parses namespace template, elements in brackets are dynamic
+func parseTemplate(template string) (core.Namespace, error) {
+	namespace := core.Namespace{}
+	for _, part := range strings.Split(strings.Trim(template, "/"), "/") {
+		if part == "" {
+			return nil, fmt.Errorf("Invalid namespace template %s: empty element", template)
+		}
+		if strings.HasPrefix(part, "[") && strings.HasSuffix(part, "]") {
+			name := part[1 : len(part)-1]
+			if name == "" {
+				return nil, fmt.Errorf("Invalid namespace template %s: empty name of dynamic element", template)
+			}
+			namespace = append(namespace, core.NamespaceElement{Value: "*", Name: name})
+			continue
+		}
+		if err := ns.ValidateMetricNamespacePart(part); err != nil {
+			return nil, fmt.Errorf("Invalid namespace template %s: %v", template, err)
+		}
+		namespace = append(namespace, core.NewNamespaceElement(part))
+	}
+	return namespace, nil
+}
+
+// restore builds namespace from template if static elements of template
+// match namespace and tags hold values of all dynamic elements
+func restore(namespace core.Namespace, tags map[string]string, template core.Namespace) (core.Namespace, bool) {
+	restored := core.Namespace{}
+	i := 0
+	for _, nse := range template {
+		if nse.Name != "" {
+			value, ok := tags[nse.Name]
+			if !ok {
+				return nil, false
+			}
+			nse.Value = value
+			restored = append(restored, nse)
+			continue
+		}
+		if i >= len(namespace) || namespace[i].Value != nse.Value {
+			return nil, false
+		}
+		restored = append(restored, namespace[i])
+		i++
+	}
+	if i != len(namespace) {
+		return nil, false
 	}
+	return restored, true
 }
 
 func contains(slice []int, lookup int) bool {
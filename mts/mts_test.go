@@ -80,6 +80,208 @@ func TestConvertDynamicElements(t *testing.T) {
 	})
 }
 
+func TestConvertDynamicElementsWithOptions(t *testing.T) {
+	Convey("Given metrics with two dynamic elements", t, func() {
+		newMetrics := func() []plugin.MetricType {
+			metrics := []plugin.MetricType{
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel").AddDynamicElement("host", "host name").AddDynamicElement("disk", "disk name").AddStaticElement("reads"),
+					Tags_:      map[string]string{"disk": "existing"},
+				},
+				plugin.MetricType{
+					Namespace_: core.NewNamespace("intel", "static"),
+				},
+			}
+			mockCollectorActionOnDynamicMetric(metrics)
+			return metrics
+		}
+
+		Convey("When tags are renamed and prefixed", func() {
+			metrics := newMetrics()
+			result, err := ConvertDynamicElementsWithOptions(metrics, RenameTags(map[string]string{"host": "hostname"}), TagPrefix("dyn_"))
+
+			Convey("Then metrics are converted in place", func() {
+				So(err, ShouldBeNil)
+				So(result[0].Namespace().String(), ShouldEqual, "/intel/reads")
+				So(result[0].Tags(), ShouldResemble, map[string]string{"disk": "existing", "dyn_hostname": "value_0_1", "dyn_disk": "value_0_2"})
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/reads")
+				So(result[1].Namespace().String(), ShouldEqual, "/intel/static")
+				So(result[1].Tags(), ShouldBeNil)
+			})
+		})
+
+		Convey("When existing tags are kept", func() {
+			result, err := ConvertDynamicElementsWithOptions(newMetrics(), OnConflict(KeepExisting))
+
+			Convey("Then values of conflicting elements are dropped", func() {
+				So(err, ShouldBeNil)
+				So(result[0].Tags(), ShouldResemble, map[string]string{"disk": "existing", "host": "value_0_1"})
+			})
+		})
+
+		Convey("When existing tags are overwritten", func() {
+			result, _ := ConvertDynamicElementsWithOptions(newMetrics())
+
+			Convey("Then values of dynamic elements win", func() {
+				So(result[0].Tags(), ShouldResemble, map[string]string{"disk": "value_0_2", "host": "value_0_1"})
+			})
+		})
+
+		Convey("When conflicts are errors", func() {
+			metrics := newMetrics()
+			_, err := ConvertDynamicElementsWithOptions(metrics, OnConflict(FailOnConflict))
+
+			Convey("Then error is returned and metrics are unchanged", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "Tag disk of metric /intel/value_0_1/value_0_2/reads already exists")
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/value_0_1/value_0_2/reads")
+				So(metrics[0].Tags(), ShouldResemble, map[string]string{"disk": "existing"})
+			})
+		})
+
+		Convey("When only selected elements are converted into copy", func() {
+			metrics := newMetrics()
+			result, err := ConvertDynamicElementsWithOptions(metrics, OnlyElements("host"), ReturnCopy())
+
+			Convey("Then other dynamic elements stay and original metrics are unchanged", func() {
+				So(err, ShouldBeNil)
+				So(result[0].Namespace().String(), ShouldEqual, "/intel/value_0_2/reads")
+				So(result[0].Namespace()[1].Name, ShouldEqual, "disk")
+				So(result[0].Tags(), ShouldResemble, map[string]string{"disk": "existing", "host": "value_0_1"})
+				So(metrics[0].Namespace().String(), ShouldEqual, "/intel/value_0_1/value_0_2/reads")
+				So(metrics[0].Tags(), ShouldResemble, map[string]string{"disk": "existing"})
+			})
+		})
+
+		Convey("When copy is converted and restored", func() {
+			metrics := newMetrics()
+			metrics[1].Tags_ = map[string]string{"host": "host1"}
+			result, err := ConvertDynamicElementsWithOptions(metrics, ReturnCopy())
+			So(err, ShouldBeNil)
+			So(&result[1].Namespace()[0], ShouldNotPointTo, &metrics[1].Namespace()[0])
+			result[1].Tags_["rack"] = "rack1"
+			So(RestoreDynamicElements(result, "/intel/[host]/[disk]/reads", "/intel/[host]/static"), ShouldBeNil)
+
+			Convey("Then copy is restored and original metrics are untouched", func() {
+				So(result[0].Namespace().String(), ShouldEqual, "/intel/value_0_1/value_0_2/reads")
+				So(result[1].Namespace().String(), ShouldEqual, "/intel/host1/static")
+				expected := newMetrics()
+				expected[1].Tags_ = map[string]string{"host": "host1"}
+				So(metrics, ShouldResemble, expected)
+			})
+		})
+	})
+}
+
+func TestRestoreDynamicElements(t *testing.T) {
+	Convey("Given metrics with dynamic elements converted to tags", t, func() {
+		metrics := []plugin.MetricType{
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "mock", "foo"),
+				Tags_:      map[string]string{},
+			},
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "mock").AddDynamicElement("foos", "foos contains many foo").AddStaticElement("bar"),
+				Tags_:      map[string]string{core.STD_TAG_PLUGIN_RUNNING_ON: "127.0.0.1"},
+			},
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "mock").AddDynamicElement("foos", "foos contains many foo").AddStaticElements("bar", "tar"),
+				Tags_:      map[string]string{},
+			},
+			plugin.MetricType{
+				Namespace_: core.NewNamespace("intel").AddDynamicElement("foos", "foos contains many foo").AddDynamicElement("bars", "bars contains many bar").AddStaticElement("tar"),
+				Tags_:      map[string]string{},
+			},
+		}
+		catalog := []core.Namespace{}
+		for _, m := range metrics {
+			catalog = append(catalog, append(core.Namespace{}, m.Namespace()...))
+		}
+		mockCollectorActionOnDynamicMetric(metrics)
+		original := []plugin.MetricType{}
+		for _, m := range metrics {
+			tags := map[string]string{}
+			for k, v := range m.Tags() {
+				tags[k] = v
+			}
+			original = append(original, plugin.MetricType{Namespace_: append(core.Namespace{}, m.Namespace()...), Tags_: tags})
+		}
+		ConvertDynamicElements(metrics)
+
+		Convey("When tags are turned back into dynamic elements", func() {
+			err := RestoreDynamicElements(metrics,
+				"/intel/mock/[foos]/bar",
+				"/intel/mock/[foos]/bar/tar",
+				"/intel/[foos]/[bars]/tar")
+			So(err, ShouldBeNil)
+
+			Convey("Then original namespaces and tags are restored", func() {
+				for i, m := range metrics {
+					So(m.Namespace().String(), ShouldEqual, original[i].Namespace().String())
+					So(len(m.Namespace()), ShouldEqual, len(original[i].Namespace()))
+					for j, nse := range m.Namespace() {
+						So(nse.Name, ShouldEqual, original[i].Namespace()[j].Name)
+					}
+					So(m.Tags(), ShouldResemble, original[i].Tags())
+				}
+			})
+
+			Convey("Then descriptions of dynamic elements are not known", func() {
+				So(metrics[1].Namespace()[2].Description, ShouldEqual, "")
+			})
+
+			Convey("Then converting them again gives the same tags", func() {
+				ConvertDynamicElements(metrics)
+				So(metrics[3].Namespace().String(), ShouldEqual, "/intel/tar")
+				So(metrics[3].Tags(), ShouldResemble, map[string]string{"foos": "value_3_1", "bars": "value_3_2"})
+			})
+		})
+
+		Convey("When tags are turned back by catalog namespaces", func() {
+			RestoreDynamicNamespaces(metrics, catalog...)
+
+			Convey("Then original namespaces including descriptions are restored", func() {
+				for i, m := range metrics {
+					So(m.Namespace(), ShouldResemble, original[i].Namespace())
+					So(m.Tags(), ShouldResemble, original[i].Tags())
+				}
+				So(metrics[1].Namespace()[2].Description, ShouldEqual, "foos contains many foo")
+			})
+		})
+
+		Convey("When template does not match", func() {
+			err := RestoreDynamicElements(metrics, "/intel/mock/[foos]/[bars]/bar", "/intel/[other]/bar")
+			So(err, ShouldBeNil)
+
+			Convey("Then metrics are left unchanged", func() {
+				So(metrics[1].Namespace().String(), ShouldEqual, "/intel/mock/bar")
+				So(metrics[1].Tags(), ShouldContainKey, "foos")
+			})
+		})
+
+		Convey("When restored metric shares tags with other one", func() {
+			shared := map[string]string{"foos": "value_1_2"}
+			metrics[1].Tags_ = shared
+			metrics[2].Tags_ = shared
+			err := RestoreDynamicElements(metrics[1:2], "/intel/mock/[foos]/bar")
+			So(err, ShouldBeNil)
+
+			Convey("Then tags of the other metric are not changed", func() {
+				So(metrics[1].Tags(), ShouldBeEmpty)
+				So(metrics[2].Tags(), ShouldResemble, map[string]string{"foos": "value_1_2"})
+			})
+		})
+
+		Convey("When template is invalid", func() {
+			Convey("Then error is returned", func() {
+				So(RestoreDynamicElements(metrics, "/intel//bar"), ShouldNotBeNil)
+				So(RestoreDynamicElements(metrics, "/intel/[]/bar"), ShouldNotBeNil)
+				So(RestoreDynamicElements(metrics, "/intel/a.b/[foos]"), ShouldNotBeNil)
+			})
+		})
+	})
+}
+
 func mockCollectorActionOnDynamicMetric(metrics []plugin.MetricType) {
 	for i, metric := range metrics {
 		if isDynamic, indexes := metric.Namespace().IsDynamic(); isDynamic {
@@ -0,0 +1,135 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// NormalizeRules control conversion of metric data by Normalize
+type NormalizeRules struct {
+	// NumbersAsFloat converts all numbers to float64, otherwise signed
+	// integers become int64, unsigned uint64 and floats float64
+	NumbersAsFloat bool
+	// BoolAsNumber converts bool to number 1 or 0
+	BoolAsNumber bool
+	// ParseStrings converts strings holding finite numbers (as accepted by
+	// strconv) or "true" and "false" to numbers or bools
+	ParseStrings bool
+	// Stringify converts other types (e.g. structs) to string with fmt.Sprint,
+	// otherwise they are an error
+	Stringify bool
+}
+
+// Normalize converts data to one of canonical types: float64, int64, uint64,
+// string or bool, according to rules. Pointers are dereferenced, nil is an error.
+func Normalize(data interface{}, rules NormalizeRules) (interface{}, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rules.number(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rules.NumbersAsFloat {
+			return float64(v.Uint()), nil
+		}
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Bool:
+		if !rules.BoolAsNumber {
+			return v.Bool(), nil
+		}
+		if v.Bool() {
+			return rules.number(1), nil
+		}
+		return rules.number(0), nil
+	case reflect.String:
+		if rules.ParseStrings {
+			return rules.parse(v.String()), nil
+		}
+		return v.String(), nil
+	case reflect.Invalid, reflect.Ptr:
+		return nil, fmt.Errorf("Cannot normalize nil value")
+	}
+	if rules.Stringify {
+		return fmt.Sprint(v.Interface()), nil
+	}
+	return nil, fmt.Errorf("Cannot normalize value %v of type %T", data, data)
+}
+
+func (rules NormalizeRules) number(n int64) interface{} {
+	if rules.NumbersAsFloat {
+		return float64(n)
+	}
+	return n
+}
+
+func (rules NormalizeRules) parse(s string) interface{} {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return rules.number(n)
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		if rules.NumbersAsFloat {
+			return float64(n)
+		}
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f
+	}
+	if s == "true" || s == "false" {
+		b := s == "true"
+		if !rules.BoolAsNumber {
+			return b
+		}
+		if b {
+			return rules.number(1)
+		}
+		return rules.number(0)
+	}
+	return s
+}
+
+// NormalizeMetrics returns metrics with normalized data. Metrics which cannot
+// be normalized are left out of returned slice and first error is returned.
+func NormalizeMetrics(metrics []plugin.MetricType, rules NormalizeRules) ([]plugin.MetricType, error) {
+	result := []plugin.MetricType{}
+	var first error
+	for _, m := range metrics {
+		data, err := Normalize(m.Data(), rules)
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("Metric %s: %v", m.Namespace().String(), err)
+			}
+			continue
+		}
+		m.Data_ = data
+		result = append(result, m)
+	}
+	return result, first
+}
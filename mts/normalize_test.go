@@ -0,0 +1,128 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+type composite struct {
+	A int
+}
+
+func TestNormalize(t *testing.T) {
+	Convey("Given default rules", t, func() {
+		rules := NormalizeRules{}
+
+		Convey("Then numbers are converted to canonical types", func() {
+			i := int16(-3)
+			cases := map[interface{}]interface{}{
+				int8(-1):    int64(-1),
+				int32(5):    int64(5),
+				uint8(7):    uint64(7),
+				uint32(9):   uint64(9),
+				float32(.5): float64(.5),
+				true:        true,
+				"12":        "12",
+				&i:          int64(-3),
+			}
+			for data, expected := range cases {
+				v, err := Normalize(data, rules)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, expected)
+			}
+		})
+
+		Convey("Then other types are an error", func() {
+			_, err := Normalize(composite{A: 1}, rules)
+			So(err.Error(), ShouldEqual, "Cannot normalize value {1} of type mts.composite")
+			_, err = Normalize(nil, rules)
+			So(err, ShouldNotBeNil)
+			var p *int
+			_, err = Normalize(p, rules)
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given rules converting everything to numbers", t, func() {
+		rules := NormalizeRules{NumbersAsFloat: true, BoolAsNumber: true, ParseStrings: true, Stringify: true}
+
+		Convey("Then values are converted", func() {
+			cases := map[interface{}]interface{}{
+				int64(-1):              float64(-1),
+				uint64(7):              float64(7),
+				true:                   float64(1),
+				"12":                   float64(12),
+				"18446744073709551615": float64(18446744073709551615),
+				"1.5":                  1.5,
+				"false":                float64(0),
+				"n/a":                  "n/a",
+				composite{A: 1}:        "{1}",
+			}
+			for data, expected := range cases {
+				v, err := Normalize(data, rules)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, expected)
+			}
+		})
+	})
+
+	Convey("Given rules parsing strings", t, func() {
+		rules := NormalizeRules{ParseStrings: true}
+
+		Convey("Then integers keep their kind", func() {
+			v, _ := Normalize("-12", rules)
+			So(v, ShouldEqual, int64(-12))
+			v, _ = Normalize("18446744073709551615", rules)
+			So(v, ShouldEqual, uint64(18446744073709551615))
+			v, _ = Normalize("true", rules)
+			So(v, ShouldEqual, true)
+		})
+
+		Convey("Then other bool spellings and non-finite numbers stay strings", func() {
+			for _, s := range []string{"t", "F", "TRUE", "NaN", "Inf", "-inf", "1e400"} {
+				v, err := Normalize(s, rules)
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, s)
+			}
+		})
+	})
+
+	Convey("Given metrics with various data", t, func() {
+		metrics := []plugin.MetricType{
+			plugin.MetricType{Namespace_: core.NewNamespace("a"), Data_: int32(1)},
+			plugin.MetricType{Namespace_: core.NewNamespace("b"), Data_: composite{}},
+			plugin.MetricType{Namespace_: core.NewNamespace("c"), Data_: uint16(2)},
+		}
+		result, err := NormalizeMetrics(metrics, NormalizeRules{})
+
+		Convey("Then metrics which can be normalized are returned", func() {
+			So(err.Error(), ShouldEqual, "Metric /b: Cannot normalize value {0} of type mts.composite")
+			So(len(result), ShouldEqual, 2)
+			So(result[0].Data(), ShouldEqual, int64(1))
+			So(result[1].Data(), ShouldEqual, uint64(2))
+		})
+	})
+}
@@ -0,0 +1,461 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/ns"
+)
+
+// ParseOptions control parsing of metrics from wire formats
+type ParseOptions struct {
+	// Prefix is prepended to namespace of parsed metrics, e.g. `intel`, `statsd`
+	Prefix []string
+	// Separator splits metric names into namespace elements, by default
+	// names are split by `/` in InfluxDB line protocol (as written by
+	// WriteInflux), by `.` in StatsD and Prometheus names are one element
+	Separator string
+	// Precision of timestamps in InfluxDB line protocol, default time.Nanosecond
+	Precision time.Duration
+	// Timestamp of metrics without one, default is time of parsing
+	Timestamp time.Time
+}
+
+// ParseError describes malformed line of input
+type ParseError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Cannot parse line %d %q: %v", e.Line, e.Text, e.Err)
+}
+
+// Parser reads metrics from r in one of wire formats. Malformed lines are
+// reported as ParseError and skipped, so metrics of valid lines are always
+// returned.
+type Parser func(r io.Reader, opts ParseOptions) ([]plugin.MetricType, []error)
+
+// Parsers are available wire formats by name
+var Parsers = map[string]Parser{
+	"influx":     ParseInflux,
+	"prometheus": ParsePrometheus,
+	"statsd":     ParseStatsD,
+}
+
+// Parse reads metrics from r in format given by name, see Parsers
+func Parse(r io.Reader, format string, opts ParseOptions) ([]plugin.MetricType, []error) {
+	parser, ok := Parsers[format]
+	if !ok {
+		return nil, []error{fmt.Errorf("Unknown parsing format %s", format)}
+	}
+	return parser(r, opts)
+}
+
+// parseLines calls parse for every line of r which is not empty, collecting
+// metrics and errors
+func parseLines(r io.Reader, parse func(line string) ([]plugin.MetricType, error)) ([]plugin.MetricType, []error) {
+	metrics := []plugin.MetricType{}
+	errs := []error{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		parsed, err := parse(line)
+		if err != nil {
+			errs = append(errs, &ParseError{Line: n, Text: line, Err: err})
+			continue
+		}
+		metrics = append(metrics, parsed...)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("Cannot read metrics: %v", err))
+	}
+	return metrics, errs
+}
+
+// ParseInflux reads metrics from InfluxDB line protocol. Every field becomes
+// a metric whose namespace is measurement followed by field key, except
+// field `value` which is left out, so output of WriteInflux is parsed back
+// into original metrics. Integer, unsigned, float, bool and string fields are
+// supported, tags become tags of metrics, comments starting with `#` are skipped.
+func ParseInflux(r io.Reader, opts ParseOptions) ([]plugin.MetricType, []error) {
+	precision := opts.Precision
+	if precision <= 0 {
+		precision = time.Nanosecond
+	}
+	now := opts.now()
+	return parseLines(r, func(line string) ([]plugin.MetricType, error) {
+		if strings.HasPrefix(line, "#") {
+			return nil, nil
+		}
+		// measurement and tags end with first unescaped space,
+		// quotes are literal there
+		key := splitUnescaped(line, ' ', false)[0]
+		sections := splitUnescaped(line[len(key):], ' ', true)[1:]
+		if len(sections) < 1 || len(sections) > 2 || sections[0] == "" {
+			return nil, fmt.Errorf("Expected measurement, fields and optional timestamp")
+		}
+		timestamp := now
+		if len(sections) == 2 {
+			t, err := strconv.ParseInt(sections[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid timestamp %s", sections[1])
+			}
+			if t > math.MaxInt64/int64(precision) || t < math.MinInt64/int64(precision) {
+				return nil, fmt.Errorf("Timestamp %s out of range", sections[1])
+			}
+			timestamp = time.Unix(0, t*int64(precision))
+		}
+
+		keys := splitUnescaped(key, ',', false)
+		tags := map[string]string{}
+		for _, tag := range keys[1:] {
+			kv := splitUnescaped(tag, '=', false)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, fmt.Errorf("Invalid tag %s", tag)
+			}
+			tags[influxUnescaper.Replace(kv[0])] = influxUnescaper.Replace(kv[1])
+		}
+		measurement := influxUnescaper.Replace(keys[0])
+
+		metrics := []plugin.MetricType{}
+		for _, field := range splitUnescaped(sections[0], ',', true) {
+			kv := splitUnescaped(field, '=', true)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, fmt.Errorf("Invalid field %s", field)
+			}
+			data, err := parseInfluxValue(kv[1])
+			if err != nil {
+				return nil, err
+			}
+			namespace, err := opts.namespace(measurement, "/")
+			if err != nil {
+				return nil, err
+			}
+			if name := influxUnescaper.Replace(kv[0]); name != "value" {
+				element := ns.ReplaceNotAllowedCharsInNamespacePart(name)
+				if element == "" {
+					return nil, fmt.Errorf("Invalid field name %s", name)
+				}
+				namespace = namespace.AddStaticElement(element)
+			}
+			metrics = append(metrics, plugin.MetricType{
+				Namespace_: namespace,
+				Tags_:      copyTags(tags),
+				Timestamp_: timestamp,
+				Data_:      data,
+			})
+		}
+		return metrics, nil
+	})
+}
+
+var influxUnescaper = strings.NewReplacer(`\,`, `,`, `\=`, `=`, `\ `, ` `)
+
+func parseInfluxValue(value string) (interface{}, error) {
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1]), nil
+	}
+	var data interface{}
+	var err error
+	switch {
+	case strings.HasSuffix(value, "i"):
+		data, err = strconv.ParseInt(value[:len(value)-1], 10, 64)
+	case strings.HasSuffix(value, "u"):
+		data, err = strconv.ParseUint(value[:len(value)-1], 10, 64)
+	default:
+		f, ferr := strconv.ParseFloat(value, 64)
+		if ferr == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return nil, fmt.Errorf("Invalid field value %s, InfluxDB does not support %v", value, f)
+		}
+		data, err = f, ferr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Invalid field value %s", value)
+	}
+	return data, nil
+}
+
+// ParsePrometheus reads metrics from Prometheus text exposition format. Labels
+// become tags, `# HELP` lines descriptions of metrics (including `_sum`,
+// `_count` and `_bucket` samples of the family), other comments are skipped.
+// Data of metrics is float64, timestamps are in milliseconds.
+func ParsePrometheus(r io.Reader, opts ParseOptions) ([]plugin.MetricType, []error) {
+	now := opts.now()
+	help := map[string]string{}
+	return parseLines(r, func(line string) ([]plugin.MetricType, error) {
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) >= 3 && fields[1] == "HELP" {
+				text := ""
+				if len(fields) == 4 {
+					text = prometheusHelpUnescaper.Replace(fields[3])
+				}
+				help[fields[2]] = text
+			}
+			return nil, nil
+		}
+
+		end := strings.IndexAny(line, "{ \t")
+		if end <= 0 {
+			return nil, fmt.Errorf("Expected metric name and value")
+		}
+		name, rest := line[:end], strings.TrimLeft(line[end:], " \t")
+		tags := map[string]string{}
+		if strings.HasPrefix(rest, "{") {
+			var err error
+			if tags, rest, err = parsePrometheusLabels(rest[1:]); err != nil {
+				return nil, err
+			}
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 1 || len(fields) > 2 {
+			return nil, fmt.Errorf("Expected value and optional timestamp")
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid value %s", fields[0])
+		}
+		timestamp := now
+		if len(fields) == 2 {
+			t, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid timestamp %s", fields[1])
+			}
+			timestamp = time.Unix(0, t*int64(time.Millisecond))
+		}
+		namespace, err := opts.namespace(name, "")
+		if err != nil {
+			return nil, err
+		}
+		description, ok := help[name]
+		for _, suffix := range []string{"_sum", "_count", "_bucket"} {
+			if !ok && strings.HasSuffix(name, suffix) {
+				description, ok = help[strings.TrimSuffix(name, suffix)]
+			}
+		}
+		return []plugin.MetricType{{
+			Namespace_:   namespace,
+			Tags_:        tags,
+			Description_: description,
+			Timestamp_:   timestamp,
+			Data_:        value,
+		}}, nil
+	})
+}
+
+var (
+	prometheusHelpUnescaper  = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+	prometheusLabelUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\"`, `"`)
+)
+
+// parsePrometheusLabels parses labels following `{` until closing `}`,
+// it returns them together with the rest of line
+func parsePrometheusLabels(s string) (map[string]string, string, error) {
+	labels := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if strings.HasPrefix(s, "}") {
+			return labels, s[1:], nil
+		}
+		eq := strings.Index(s, "=")
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("Invalid labels")
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if !strings.HasPrefix(s, `"`) {
+			return nil, "", fmt.Errorf("Expected quoted value of label %s", name)
+		}
+		end := -1
+		for i := 1; i < len(s) && end < 0; i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				end = i
+			}
+		}
+		if end < 0 {
+			return nil, "", fmt.Errorf("Unterminated value of label %s", name)
+		}
+		labels[name] = prometheusLabelUnescaper.Replace(s[1:end])
+		s = strings.TrimLeft(s[end+1:], " \t")
+		if strings.HasPrefix(s, ",") {
+			s = s[1:]
+		} else if !strings.HasPrefix(s, "}") {
+			return nil, "", fmt.Errorf("Invalid labels")
+		}
+	}
+}
+
+// ParseStatsD reads metrics from StatsD lines like `name:value|type|@rate`
+// with optional DogStatsD tags `|#tag:value,tag2` and timestamp `|T<seconds>`.
+// Counters are scaled by sample rate, timers have unit ms and data of sets
+// is string, other data is float64. Gauge deltas (`+1`, `-1`) are parsed as values.
+func ParseStatsD(r io.Reader, opts ParseOptions) ([]plugin.MetricType, []error) {
+	now := opts.now()
+	return parseLines(r, func(line string) ([]plugin.MetricType, error) {
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			return nil, fmt.Errorf("Expected name:value|type")
+		}
+		fields := strings.Split(line[colon+1:], "|")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Expected name:value|type")
+		}
+		metric := plugin.MetricType{Tags_: map[string]string{}, Timestamp_: now}
+		rate := 1.0
+		for _, field := range fields[2:] {
+			switch {
+			case strings.HasPrefix(field, "@"):
+				sampleRate, err := strconv.ParseFloat(field[1:], 64)
+				if err != nil || sampleRate <= 0 || sampleRate > 1 {
+					return nil, fmt.Errorf("Invalid sample rate %s", field[1:])
+				}
+				rate = sampleRate
+			case strings.HasPrefix(field, "#"):
+				for _, tag := range strings.Split(field[1:], ",") {
+					kv := strings.SplitN(tag, ":", 2)
+					if kv[0] == "" {
+						return nil, fmt.Errorf("Invalid tag %s", tag)
+					}
+					metric.Tags_[kv[0]] = ""
+					if len(kv) == 2 {
+						metric.Tags_[kv[0]] = kv[1]
+					}
+				}
+			case strings.HasPrefix(field, "T"):
+				t, err := strconv.ParseInt(field[1:], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid timestamp %s", field[1:])
+				}
+				metric.Timestamp_ = time.Unix(t, 0)
+			default:
+				return nil, fmt.Errorf("Unknown field %s", field)
+			}
+		}
+
+		value := fields[0]
+		switch fields[1] {
+		case "s":
+			metric.Data_ = value
+		case "c", "g", "ms", "h", "d":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value %s", value)
+			}
+			if fields[1] == "c" {
+				f /= rate
+			}
+			if fields[1] == "ms" {
+				metric.Unit_ = "ms"
+			}
+			metric.Data_ = f
+		default:
+			return nil, fmt.Errorf("Unknown metric type %s", fields[1])
+		}
+
+		namespace, err := opts.namespace(line[:colon], ".")
+		if err != nil {
+			return nil, err
+		}
+		metric.Namespace_ = namespace
+		return []plugin.MetricType{metric}, nil
+	})
+}
+
+// namespace returns namespace of metric name split by separator (or
+// defaultSeparator if not given in options) with prefix prepended,
+// all elements are sanitized
+func (opts ParseOptions) namespace(name, defaultSeparator string) (core.Namespace, error) {
+	separator := opts.Separator
+	if separator == "" {
+		separator = defaultSeparator
+	}
+	parts := []string{name}
+	if separator != "" {
+		parts = strings.Split(name, separator)
+	}
+	elements := []string{}
+	for _, part := range append(append([]string{}, opts.Prefix...), parts...) {
+		element := ns.ReplaceNotAllowedCharsInNamespacePart(part)
+		if element == "" {
+			return nil, fmt.Errorf("Invalid metric name %s", name)
+		}
+		elements = append(elements, element)
+	}
+	return core.NewNamespace(elements...), nil
+}
+
+func (opts ParseOptions) now() time.Time {
+	if opts.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return opts.Timestamp
+}
+
+// splitUnescaped splits s by sep not preceded by backslash and, if quoted,
+// not enclosed in double quotes
+func splitUnescaped(s string, sep byte, quoted bool) []string {
+	parts := []string{}
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"' && quoted:
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	result := make(map[string]string, len(tags))
+	for k, v := range tags {
+		result[k] = v
+	}
+	return result
+}
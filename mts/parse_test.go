@@ -0,0 +1,190 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Unix(1465000000, 0)
+	parse := func(format string, input string, opts ParseOptions) ([]plugin.MetricType, []error) {
+		if opts.Timestamp.IsZero() {
+			opts.Timestamp = now
+		}
+		return Parse(strings.NewReader(input), format, opts)
+	}
+
+	Convey("Given InfluxDB line protocol", t, func() {
+		input := strings.Join([]string{
+			"# comment",
+			`intel/disk/reads,disk=sda,host=my\ host value=12i 1465000000123456789`,
+			`cpu,core=0 user=0.5,idle=99u,ok=t,state="up, \"ok\""`,
+			"",
+			"broken",
+			"cpu value=x",
+		}, "\n")
+
+		Convey("Then each field becomes a metric", func() {
+			metrics, errs := parse("influx", input, ParseOptions{})
+			So(len(metrics), ShouldEqual, 5)
+			So(metrics[0].Namespace().String(), ShouldEqual, "/intel/disk/reads")
+			So(metrics[0].Tags(), ShouldResemble, map[string]string{"disk": "sda", "host": "my host"})
+			So(metrics[0].Data(), ShouldEqual, int64(12))
+			So(metrics[0].Timestamp().UnixNano(), ShouldEqual, 1465000000123456789)
+
+			So(metrics[1].Namespace().String(), ShouldEqual, "/cpu/user")
+			So(metrics[1].Data(), ShouldEqual, 0.5)
+			So(metrics[1].Timestamp(), ShouldEqual, now)
+			So(metrics[2].Data(), ShouldEqual, uint64(99))
+			So(metrics[3].Data(), ShouldEqual, true)
+			So(metrics[4].Namespace().String(), ShouldEqual, "/cpu/state")
+			So(metrics[4].Data(), ShouldEqual, `up, "ok"`)
+
+			Convey("And malformed lines are reported", func() {
+				So(len(errs), ShouldEqual, 2)
+				So(errs[0].(*ParseError).Line, ShouldEqual, 5)
+				So(errs[1].(*ParseError).Line, ShouldEqual, 6)
+				So(errs[1].Error(), ShouldContainSubstring, "Invalid field value x")
+			})
+		})
+
+		Convey("Then precision and prefix are applied", func() {
+			metrics, errs := parse("influx", "disk.io,dev=sd(a) value=1 1465000000", ParseOptions{Prefix: []string{"intel"}, Precision: time.Second})
+			So(errs, ShouldBeEmpty)
+			So(metrics[0].Namespace().String(), ShouldEqual, "/intel/disk_io")
+			So(metrics[0].Tags()["dev"], ShouldEqual, "sd(a)")
+			So(metrics[0].Timestamp(), ShouldEqual, now)
+		})
+
+		Convey("Then non-finite values and timestamps out of range are reported", func() {
+			metrics, errs := parse("influx", "cpu value=NaN\ncpu value=-Inf\ncpu value=1 9223372036854775807", ParseOptions{Precision: time.Second})
+			So(metrics, ShouldBeEmpty)
+			So(len(errs), ShouldEqual, 3)
+			So(errs[0].(*ParseError).Line, ShouldEqual, 1)
+			So(errs[0].Error(), ShouldContainSubstring, "Invalid field value NaN")
+			So(errs[1].Error(), ShouldContainSubstring, "Invalid field value -Inf")
+			So(errs[2].(*ParseError).Line, ShouldEqual, 3)
+			So(errs[2].Error(), ShouldContainSubstring, "Timestamp 9223372036854775807 out of range")
+		})
+
+		Convey("Then output of WriteInflux is parsed back", func() {
+			metric := plugin.MetricType{
+				Namespace_: core.NewNamespace("intel", "service", "state"),
+				Tags_:      map[string]string{"name": `say "hi", x=1`},
+				Timestamp_: now,
+				Data_:      `a\b "c"`,
+			}
+			buf := &bytes.Buffer{}
+			So(WriteInflux(buf, []plugin.MetricType{metric}, SerializeOptions{}), ShouldBeNil)
+			metrics, errs := ParseInflux(buf, ParseOptions{})
+			So(errs, ShouldBeEmpty)
+			So(metrics, ShouldResemble, []plugin.MetricType{metric})
+		})
+	})
+
+	Convey("Given Prometheus exposition format", t, func() {
+		input := strings.Join([]string{
+			`# HELP http_requests_total Number of requests\nby code`,
+			"# TYPE http_requests_total counter",
+			`http_requests_total{code="200",path="/a \"b\""} 1027 1465000000000`,
+			`http_requests_total { code = "500" , } 3`,
+			"# HELP latency_seconds Latency",
+			`latency_seconds_count 7`,
+			"temperature +Inf",
+			`bad{code="200} 1`,
+			"bad",
+		}, "\n")
+
+		Convey("Then samples become metrics", func() {
+			metrics, errs := parse("prometheus", input, ParseOptions{Prefix: []string{"app"}})
+			So(len(metrics), ShouldEqual, 4)
+			So(metrics[0].Namespace().String(), ShouldEqual, "/app/http_requests_total")
+			So(metrics[0].Tags(), ShouldResemble, map[string]string{"code": "200", "path": `/a "b"`})
+			So(metrics[0].Data(), ShouldEqual, 1027.0)
+			So(metrics[0].Description(), ShouldEqual, "Number of requests\nby code")
+			So(metrics[0].Timestamp(), ShouldEqual, now)
+			So(metrics[1].Tags(), ShouldResemble, map[string]string{"code": "500"})
+			So(metrics[2].Description(), ShouldEqual, "Latency")
+			So(math.IsInf(metrics[3].Data().(float64), 1), ShouldBeTrue)
+
+			Convey("And malformed lines are reported", func() {
+				So(len(errs), ShouldEqual, 2)
+				So(errs[0].(*ParseError).Line, ShouldEqual, 8)
+				So(errs[1].(*ParseError).Line, ShouldEqual, 9)
+			})
+		})
+
+		Convey("Then names can be split into elements", func() {
+			metrics, errs := parse("prometheus", "node_load1 0.5", ParseOptions{Separator: "_"})
+			So(errs, ShouldBeEmpty)
+			So(metrics[0].Namespace().String(), ShouldEqual, "/node/load1")
+		})
+	})
+
+	Convey("Given StatsD lines", t, func() {
+		input := strings.Join([]string{
+			"page.views:2|c|@0.5",
+			"queue.size:-3|g|#env:prod,canary",
+			"request time:320|ms|T1465000001",
+			"users:alice|s",
+			"page.views:1|x",
+			"page.views:1|c|@2",
+			"page..views:1|c",
+			"nocolon",
+		}, "\n")
+
+		Convey("Then they become metrics", func() {
+			metrics, errs := parse("statsd", input, ParseOptions{})
+			So(len(metrics), ShouldEqual, 4)
+			So(metrics[0].Namespace().String(), ShouldEqual, "/page/views")
+			So(metrics[0].Data(), ShouldEqual, 4.0)
+			So(metrics[0].Timestamp(), ShouldEqual, now)
+			So(metrics[1].Data(), ShouldEqual, -3.0)
+			So(metrics[1].Tags(), ShouldResemble, map[string]string{"env": "prod", "canary": ""})
+			So(metrics[2].Namespace().String(), ShouldEqual, "/request_time")
+			So(metrics[2].Unit(), ShouldEqual, "ms")
+			So(metrics[2].Timestamp(), ShouldEqual, now.Add(time.Second))
+			So(metrics[3].Data(), ShouldEqual, "alice")
+
+			Convey("And malformed lines are reported", func() {
+				So(len(errs), ShouldEqual, 4)
+				So(errs[0].Error(), ShouldContainSubstring, "Unknown metric type x")
+				So(errs[1].Error(), ShouldContainSubstring, "Invalid sample rate 2")
+				So(errs[2].Error(), ShouldContainSubstring, "Invalid metric name page..views")
+				So(errs[3].(*ParseError).Line, ShouldEqual, 8)
+			})
+		})
+	})
+
+	Convey("Given unknown format", t, func() {
+		_, errs := parse("xml", "", ParseOptions{})
+		So(len(errs), ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,185 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// RateCalculator converts monotonically increasing counters to per-second
+// rates. It keeps the previous value and timestamp of each series (see
+// SeriesKey). It is safe for concurrent use.
+//
+// First sample of series gives no rate. When counter decreases it is
+// considered wrapped if its previous value was in the upper half of the range
+// of its type (32 bits for uint32 and int32, 64 bits for uint64, int64, uint
+// and int), otherwise counter was reset and the sample only starts a new
+// baseline. Floating point counters do not wrap.
+type RateCalculator struct {
+	mutex    sync.Mutex
+	previous map[string]counterSample
+}
+
+type counterSample struct {
+	integer bool
+	// bits is width at which integer counter wraps
+	bits int
+	u    uint64
+	f    float64
+	time time.Time
+}
+
+// NewRateCalculator creates rate calculator without any history
+func NewRateCalculator() *RateCalculator {
+	return &RateCalculator{previous: map[string]counterSample{}}
+}
+
+func newCounterSample(data interface{}, t time.Time) (counterSample, error) {
+	s := counterSample{integer: true, bits: 64, time: t}
+	switch v := data.(type) {
+	case uint64:
+		s.u = v
+	case uint32:
+		s.u, s.bits = uint64(v), 32
+	case uint:
+		s.u = uint64(v)
+	case uint16:
+		s.u, s.bits = uint64(v), 16
+	case uint8:
+		s.u, s.bits = uint64(v), 8
+	case int64:
+		s.u = uint64(v)
+		s.integer = v >= 0
+		s.f = float64(v)
+	case int32:
+		s.u, s.bits = uint64(v), 32
+		s.integer = v >= 0
+		s.f = float64(v)
+	case int:
+		s.u = uint64(v)
+		s.integer = v >= 0
+		s.f = float64(v)
+	case float64:
+		s.integer, s.f = false, v
+	case float32:
+		s.integer, s.f = false, float64(v)
+	default:
+		return s, fmt.Errorf("value %v of type %T is not a counter", data, data)
+	}
+	return s, nil
+}
+
+// delta returns increase of counter since previous sample, ok is false
+// if counter was reset
+func (s counterSample) delta(prev counterSample) (float64, bool) {
+	if !s.integer || !prev.integer {
+		cur, last := s.value(), prev.value()
+		return cur - last, cur >= last
+	}
+	if s.u >= prev.u {
+		return float64(s.u - prev.u), true
+	}
+	limit := uint64(math.MaxUint64) >> uint(64-s.bits)
+	if s.bits == prev.bits && prev.u > limit/2 {
+		return float64(limit - prev.u + s.u + 1), true
+	}
+	return 0, false
+}
+
+func (s counterSample) value() float64 {
+	if s.integer {
+		return float64(s.u)
+	}
+	return s.f
+}
+
+// Rate returns per-second rate of metric counter since previous sample of
+// its series, ok is false for the first sample, after reset or if timestamp
+// did not advance. Zero timestamp of metric means now.
+func (r *RateCalculator) Rate(metric plugin.MetricType) (rate float64, ok bool, err error) {
+	t := metric.Timestamp()
+	if t.IsZero() {
+		t = time.Now()
+	}
+	s, err := newCounterSample(metric.Data(), t)
+	if err != nil {
+		return 0, false, fmt.Errorf("Metric %s: %v", metric.Namespace().String(), err)
+	}
+	key := SeriesKey(metric)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.previous == nil {
+		r.previous = map[string]counterSample{}
+	}
+	prev, found := r.previous[key]
+	if found && !t.After(prev.time) {
+		return 0, false, nil
+	}
+	r.previous[key] = s
+	if !found {
+		return 0, false, nil
+	}
+	delta, ok := s.delta(prev)
+	if !ok {
+		return 0, false, nil
+	}
+	return delta / t.Sub(prev.time).Seconds(), true, nil
+}
+
+// Rates returns metrics with counters replaced by float64 rates, metrics
+// without rate (first samples, resets) are left out. Unit gets "/s" suffix.
+// First error is returned together with rates of other metrics.
+func (r *RateCalculator) Rates(metrics []plugin.MetricType) ([]plugin.MetricType, error) {
+	result := []plugin.MetricType{}
+	var first error
+	for _, m := range metrics {
+		rate, ok, err := r.Rate(m)
+		if err != nil && first == nil {
+			first = err
+		}
+		if !ok {
+			continue
+		}
+		m.Data_ = rate
+		if m.Unit_ != "" {
+			m.Unit_ += "/s"
+		}
+		result = append(result, m)
+	}
+	return result, first
+}
+
+// Expire forgets series whose last sample is older than given time,
+// so memory does not grow with series which are no longer collected
+func (r *RateCalculator) Expire(before time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for key, s := range r.previous {
+		if s.time.Before(before) {
+			delete(r.previous, key)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestSeriesKey(t *testing.T) {
+	Convey("Given metric with dynamic element", t, func() {
+		metric := plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "disk").AddDynamicElement("disk", "disk name").AddStaticElement("reads"),
+			Tags_:      map[string]string{"host": "h1"},
+		}
+		metric.Namespace()[2].Value = "sda"
+
+		Convey("Then key has dynamic element as tag", func() {
+			So(SeriesKey(metric), ShouldEqual, "/intel/disk/reads{disk=sda,host=h1}")
+		})
+
+		Convey("Then key is the same after ConvertDynamicElements", func() {
+			key := SeriesKey(metric)
+			metrics := []plugin.MetricType{metric}
+			ConvertDynamicElements(metrics)
+			So(SeriesKey(metrics[0]), ShouldEqual, key)
+		})
+	})
+
+	Convey("Given metrics of different series with separators in values", t, func() {
+		metric := func(tags map[string]string, elements ...string) plugin.MetricType {
+			return plugin.MetricType{Namespace_: core.NewNamespace(elements...), Tags_: tags}
+		}
+
+		Convey("Then their keys differ", func() {
+			So(SeriesKey(metric(map[string]string{"a": "1,b=2"}, "x")), ShouldNotEqual, SeriesKey(metric(map[string]string{"a": "1", "b": "2"}, "x")))
+			So(SeriesKey(metric(nil, "a/b")), ShouldNotEqual, SeriesKey(metric(nil, "a", "b")))
+			So(SeriesKey(metric(map[string]string{"a=1": ""}, "x")), ShouldNotEqual, SeriesKey(metric(map[string]string{"a": "1="}, "x")))
+			So(SeriesKey(metric(map[string]string{"a": `1\`}, "x")), ShouldNotEqual, SeriesKey(metric(map[string]string{"a": `1\,`}, "x")))
+			So(SeriesKey(metric(map[string]string{"a": "1,b=2"}, "x")), ShouldEqual, `/x{a=1\,b\=2}`)
+		})
+
+		Convey("Then rates are not computed across them", func() {
+			first := metric(map[string]string{"a": "1,b=2"}, "x")
+			first.Data_, first.Timestamp_ = uint64(10), time.Unix(10, 0)
+			second := metric(map[string]string{"a": "1", "b": "2"}, "x")
+			second.Data_, second.Timestamp_ = uint64(20), time.Unix(11, 0)
+			rates := NewRateCalculator()
+			rates.Rate(first)
+			_, ok, err := rates.Rate(second)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+func TestRateCalculator(t *testing.T) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	counter := func(data interface{}, seconds int, tags map[string]string) plugin.MetricType {
+		return plugin.MetricType{
+			Namespace_: core.NewNamespace("intel", "net", "bytes"),
+			Data_:      data,
+			Timestamp_: start.Add(time.Duration(seconds) * time.Second),
+			Tags_:      tags,
+			Unit_:      "B",
+		}
+	}
+
+	Convey("Given rate calculator", t, func() {
+		r := NewRateCalculator()
+
+		Convey("Then first sample gives no rate", func() {
+			_, ok, err := r.Rate(counter(uint64(100), 0, nil))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+
+			Convey("Then next sample gives per-second rate", func() {
+				rate, ok, err := r.Rate(counter(uint64(300), 2, nil))
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeTrue)
+				So(rate, ShouldEqual, 100)
+			})
+
+			Convey("Then sample with the same timestamp gives no rate", func() {
+				_, ok, _ := r.Rate(counter(uint64(300), 0, nil))
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("Then series are separated by tags", func() {
+				_, ok, _ := r.Rate(counter(uint64(300), 2, map[string]string{"iface": "eth0"}))
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("Then reset starts new baseline", func() {
+				_, ok, _ := r.Rate(counter(uint64(10), 1, nil))
+				So(ok, ShouldBeFalse)
+				rate, ok, _ := r.Rate(counter(uint64(20), 2, nil))
+				So(ok, ShouldBeTrue)
+				So(rate, ShouldEqual, 10)
+			})
+		})
+
+		Convey("Then 32-bit counter wrap is handled", func() {
+			r.Rate(counter(uint32(math.MaxUint32-9), 0, nil))
+			rate, ok, _ := r.Rate(counter(uint32(10), 1, nil))
+			So(ok, ShouldBeTrue)
+			So(rate, ShouldEqual, 20)
+		})
+
+		Convey("Then 64-bit counter wrap is handled", func() {
+			r.Rate(counter(uint64(math.MaxUint64-99), 0, nil))
+			rate, ok, _ := r.Rate(counter(uint64(100), 10, nil))
+			So(ok, ShouldBeTrue)
+			So(rate, ShouldEqual, 20)
+		})
+
+		Convey("Then 64-bit counter above 32-bit range is reset, not wrapped", func() {
+			r.Rate(counter(uint64(3e9), 0, nil))
+			_, ok, _ := r.Rate(counter(uint64(10), 1, nil))
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Then floating point counters are supported", func() {
+			r.Rate(counter(1.5, 0, nil))
+			rate, ok, _ := r.Rate(counter(4.5, 2, nil))
+			So(ok, ShouldBeTrue)
+			So(rate, ShouldEqual, 1.5)
+		})
+
+		Convey("Then non-numeric value is an error", func() {
+			_, _, err := r.Rate(counter("x", 0, nil))
+			So(err.Error(), ShouldEqual, "Metric /intel/net/bytes: value x of type string is not a counter")
+		})
+
+		Convey("When rates of metrics are computed", func() {
+			first, err := r.Rates([]plugin.MetricType{counter(int64(0), 0, nil)})
+			So(err, ShouldBeNil)
+			So(first, ShouldBeEmpty)
+			rates, err := r.Rates([]plugin.MetricType{counter(int64(50), 5, nil), counter(true, 5, map[string]string{"a": "b"})})
+
+			Convey("Then metrics with rates are returned", func() {
+				So(err, ShouldNotBeNil)
+				So(len(rates), ShouldEqual, 1)
+				So(rates[0].Data(), ShouldEqual, 10.0)
+				So(rates[0].Unit(), ShouldEqual, "B/s")
+			})
+		})
+
+		Convey("When series expire", func() {
+			r.Rate(counter(uint64(1), 0, nil))
+			r.Expire(start.Add(time.Second))
+
+			Convey("Then their history is forgotten", func() {
+				_, ok, _ := r.Rate(counter(uint64(2), 2, nil))
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When counters are reported concurrently", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					tags := map[string]string{"worker": string(rune('a' + i))}
+					for j := 0; j < 100; j++ {
+						r.Rate(counter(uint64(j), j, tags))
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			Convey("Then each series is tracked", func() {
+				So(len(r.previous), ShouldEqual, 10)
+			})
+		})
+	})
+}
@@ -0,0 +1,424 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// SerializeOptions control serialization of metrics to wire formats
+type SerializeOptions struct {
+	// ExtractTags turns dynamic elements into tags like ConvertDynamicElements,
+	// otherwise their values stay in metric name. Tags of metric are always
+	// written if the format supports them.
+	ExtractTags bool
+	// Precision of timestamps written by WriteInflux (default time.Nanosecond)
+	// and WriteOpenTSDB (time.Second by default, time.Millisecond allowed);
+	// WriteNDJSON truncates timestamps to it. Prometheus timestamps are always
+	// in milliseconds and Graphite timestamps in seconds.
+	Precision time.Duration
+	// DefaultTags are added to metrics which do not have tags of these keys,
+	// e.g. host, as OpenTSDB requires at least one tag
+	DefaultTags map[string]string
+}
+
+// Serializer writes metrics to w in one of wire formats
+type Serializer func(w io.Writer, metrics []plugin.MetricType, opts SerializeOptions) error
+
+// Serializers are available wire formats by name
+var Serializers = map[string]Serializer{
+	"influx":     WriteInflux,
+	"prometheus": WritePrometheus,
+	"graphite":   WriteGraphite,
+	"opentsdb":   WriteOpenTSDB,
+	"ndjson":     WriteNDJSON,
+}
+
+// Serialize writes metrics to w in format given by name, see Serializers
+func Serialize(w io.Writer, format string, metrics []plugin.MetricType, opts SerializeOptions) error {
+	serializer, ok := Serializers[format]
+	if !ok {
+		return fmt.Errorf("Unknown serialization format %s", format)
+	}
+	return serializer(w, metrics, opts)
+}
+
+// WriteInflux writes metrics in InfluxDB line protocol, one point per metric
+// with namespace elements joined by `/` as measurement and data in field
+// `value`, e.g. `intel/disk/reads,disk=sda value=12i 1465000000000000000`.
+// Tags with empty values are left out as InfluxDB does not accept them.
+// Line protocol cannot escape newlines, so in measurement and tags they are
+// replaced by `_`. Timestamp is left out for metrics with zero timestamp, so server assigns it.
+func WriteInflux(w io.Writer, metrics []plugin.MetricType, opts SerializeOptions) error {
+	precision := opts.Precision
+	if precision <= 0 {
+		precision = time.Nanosecond
+	}
+	switch precision {
+	case time.Nanosecond, time.Microsecond, time.Millisecond, time.Second, time.Minute, time.Hour:
+	default:
+		return fmt.Errorf("InfluxDB does not support precision %v", precision)
+	}
+	buf := &bytes.Buffer{}
+	for _, m := range metrics {
+		elements, tags := opts.split(m)
+		value, err := influxValue(m.Data())
+		if err != nil {
+			return fmt.Errorf("Cannot serialize %s: %v", m.Namespace().String(), err)
+		}
+		buf.WriteString(influxMeasurementEscaper.Replace(strings.Join(elements, "/")))
+		for _, k := range sortedKeys(tags) {
+			if k == "" || tags[k] == "" {
+				continue
+			}
+			fmt.Fprintf(buf, ",%s=%s", influxTagEscaper.Replace(k), influxTagEscaper.Replace(tags[k]))
+		}
+		fmt.Fprintf(buf, " value=%s", value)
+		if !m.Timestamp().IsZero() {
+			fmt.Fprintf(buf, " %d", m.Timestamp().UnixNano()/int64(precision))
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `, "\n", "_")
+	influxTagEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `, "\n", "_")
+	influxStringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+func influxValue(data interface{}) (string, error) {
+	value, err := Normalize(data, NormalizeRules{})
+	if err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", nil
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u", nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("InfluxDB does not support %v", v)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return `"` + influxStringEscaper.Replace(value.(string)) + `"`, nil
+}
+
+// WritePrometheus writes metrics in Prometheus text exposition format. Namespace
+// elements are joined by `_` into metric name, characters not allowed in
+// names and label names are replaced by `_`, tags whose label names become
+// equal are an error. Metrics of the same name are
+// written together as untyped family, with description of the first of them
+// as help text. Bool data is written as 1 or 0, other non-numeric data is an error.
+func WritePrometheus(w io.Writer, metrics []plugin.MetricType, opts SerializeOptions) error {
+	names := []string{}
+	families := map[string][]string{}
+	help := map[string]string{}
+	for _, m := range metrics {
+		elements, tags := opts.split(m)
+		value, err := numericValue(m.Data())
+		if err != nil {
+			return fmt.Errorf("Cannot serialize %s: %v", m.Namespace().String(), err)
+		}
+		name := prometheusName(strings.Join(elements, "_"), true)
+		if _, ok := families[name]; !ok {
+			names = append(names, name)
+			help[name] = m.Description()
+		}
+		labels := []string{}
+		sanitized := map[string]string{}
+		for _, k := range sortedKeys(tags) {
+			label := prometheusName(k, false)
+			if other, ok := sanitized[label]; ok {
+				return fmt.Errorf("Cannot serialize %s: tags %s and %s are both written as label %s", m.Namespace().String(), other, k, label)
+			}
+			sanitized[label] = k
+			labels = append(labels, fmt.Sprintf(`%s="%s"`, label, prometheusLabelEscaper.Replace(tags[k])))
+		}
+		sample := name
+		if len(labels) > 0 {
+			sample += "{" + strings.Join(labels, ",") + "}"
+		}
+		sample += " " + prometheusValue(value)
+		if !m.Timestamp().IsZero() {
+			sample += " " + strconv.FormatInt(m.Timestamp().UnixNano()/int64(time.Millisecond), 10)
+		}
+		families[name] = append(families[name], sample)
+	}
+
+	buf := &bytes.Buffer{}
+	for _, name := range names {
+		if help[name] != "" {
+			fmt.Fprintf(buf, "# HELP %s %s\n", name, prometheusHelpEscaper.Replace(help[name]))
+		}
+		fmt.Fprintf(buf, "# TYPE %s untyped\n", name)
+		for _, sample := range families[name] {
+			buf.WriteString(sample + "\n")
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var (
+	prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	prometheusHelpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// prometheusName replaces characters not allowed in metric name (or label
+// name if colons are not allowed) by `_`
+func prometheusName(name string, colons bool) string {
+	result := []rune{}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && colons:
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				result = append(result, '_')
+			}
+		default:
+			r = '_'
+		}
+		result = append(result, r)
+	}
+	if len(result) == 0 {
+		return "_"
+	}
+	return string(result)
+}
+
+func prometheusValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// WriteGraphite writes metrics in Graphite plaintext protocol with namespace
+// elements joined by `.` as path and timestamp in seconds. Dots and whitespace
+// in elements are replaced by `_`. Tags are written in Graphite tag format,
+// e.g. `intel.disk.reads;disk=sda 12 1465000000`. Bool data is written as 1 or 0,
+// other non-numeric data is an error.
+func WriteGraphite(w io.Writer, metrics []plugin.MetricType, opts SerializeOptions) error {
+	buf := &bytes.Buffer{}
+	for _, m := range metrics {
+		elements, tags := opts.split(m)
+		value, err := numericValue(m.Data())
+		if err != nil {
+			return fmt.Errorf("Cannot serialize %s: %v", m.Namespace().String(), err)
+		}
+		path := make([]string, len(elements))
+		for i, e := range elements {
+			path[i] = graphiteEscaper.Replace(e)
+		}
+		buf.WriteString(strings.Join(path, "."))
+		for _, k := range sortedKeys(tags) {
+			if k == "" || tags[k] == "" {
+				continue
+			}
+			fmt.Fprintf(buf, ";%s=%s", graphiteTagEscaper.Replace(k), graphiteTagEscaper.Replace(tags[k]))
+		}
+		fmt.Fprintf(buf, " %s %d\n", strconv.FormatFloat(value, 'g', -1, 64), timestampOrNow(m).Unix())
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+var (
+	graphiteEscaper    = strings.NewReplacer(".", "_", " ", "_", "\t", "_", "\n", "_", ";", "_")
+	graphiteTagEscaper = strings.NewReplacer(";", "_", "~", "_", "=", "_", " ", "_", "\t", "_", "\n", "_")
+)
+
+type openTSDBPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     float64           `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// WriteOpenTSDB writes metrics as JSON array accepted by OpenTSDB `/api/put`
+// with namespace elements joined by `.` as metric name. Characters not allowed
+// by OpenTSDB in names and tags are replaced by `_`, tags with empty values
+// are left out, metric without any tag or with tags whose keys become equal
+// is an error. Bool data is written as
+// 1 or 0, other non-numeric data, NaN and infinities are an error.
+func WriteOpenTSDB(w io.Writer, metrics []plugin.MetricType, opts SerializeOptions) error {
+	precision := opts.Precision
+	if precision <= 0 {
+		precision = time.Second
+	}
+	if precision != time.Second && precision != time.Millisecond {
+		return fmt.Errorf("OpenTSDB does not support precision %v", precision)
+	}
+	points := make([]openTSDBPoint, len(metrics))
+	for i, m := range metrics {
+		elements, tags := opts.split(m)
+		value, err := numericValue(m.Data())
+		if err == nil && (math.IsNaN(value) || math.IsInf(value, 0)) {
+			err = fmt.Errorf("OpenTSDB does not support %v", value)
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot serialize %s: %v", m.Namespace().String(), err)
+		}
+		names := make([]string, len(elements))
+		for j, e := range elements {
+			names[j] = strings.Replace(openTSDBName(e), ".", "_", -1)
+		}
+		point := openTSDBPoint{
+			Metric:    strings.Join(names, "."),
+			Timestamp: timestampOrNow(m).UnixNano() / int64(precision),
+			Value:     value,
+			Tags:      map[string]string{},
+		}
+		sanitized := map[string]string{}
+		for _, k := range sortedKeys(tags) {
+			if k == "" || tags[k] == "" {
+				continue
+			}
+			key := openTSDBName(k)
+			if other, ok := sanitized[key]; ok {
+				return fmt.Errorf("Cannot serialize %s: tags %s and %s are both written as tag %s", m.Namespace().String(), other, k, key)
+			}
+			sanitized[key] = k
+			point.Tags[key] = openTSDBName(tags[k])
+		}
+		if len(point.Tags) == 0 {
+			return fmt.Errorf("Cannot serialize %s: OpenTSDB requires at least one tag, see DefaultTags", m.Namespace().String())
+		}
+		points[i] = point
+	}
+	return json.NewEncoder(w).Encode(points)
+}
+
+// openTSDBName replaces characters other than letters, digits, `-`, `_`, `.`
+// and `/` by `_`
+func openTSDBName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '-', r == '_', r == '.', r == '/':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+type jsonMetric struct {
+	Namespace string            `json:"namespace"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Unit      string            `json:"unit,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Data      interface{}       `json:"data"`
+}
+
+// WriteNDJSON writes metrics as newline-delimited JSON, one object with
+// namespace (`/` separated), tags, unit, RFC 3339 timestamp and data per line.
+func WriteNDJSON(w io.Writer, metrics []plugin.MetricType, opts SerializeOptions) error {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	for _, m := range metrics {
+		elements, tags := opts.split(m)
+		timestamp := m.Timestamp()
+		if opts.Precision > 0 {
+			timestamp = timestamp.Truncate(opts.Precision)
+		}
+		err := encoder.Encode(jsonMetric{
+			Namespace: "/" + strings.Join(elements, "/"),
+			Tags:      tags,
+			Unit:      m.Unit(),
+			Timestamp: timestamp,
+			Data:      m.Data(),
+		})
+		if err != nil {
+			return fmt.Errorf("Cannot serialize %s: %v", m.Namespace().String(), err)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// split returns namespace elements and tags of metric to be serialized
+func (opts SerializeOptions) split(m plugin.MetricType) ([]string, map[string]string) {
+	elements, tags := m.Namespace().Strings(), m.Tags()
+	if opts.ExtractTags {
+		elements, tags = splitSeries(m)
+	}
+	if len(opts.DefaultTags) == 0 {
+		return elements, tags
+	}
+	withDefaults := make(map[string]string, len(tags)+len(opts.DefaultTags))
+	for k, v := range opts.DefaultTags {
+		withDefaults[k] = v
+	}
+	for k, v := range tags {
+		withDefaults[k] = v
+	}
+	return elements, withDefaults
+}
+
+// timestampOrNow returns timestamp of metric, or current time if it is zero
+func timestampOrNow(m plugin.MetricType) time.Time {
+	if m.Timestamp().IsZero() {
+		return time.Now()
+	}
+	return m.Timestamp()
+}
+
+// numericValue returns metric data as float64, bools are 1 or 0
+func numericValue(data interface{}) (float64, error) {
+	value, err := Normalize(data, NormalizeRules{NumbersAsFloat: true, BoolAsNumber: true})
+	if err != nil {
+		return 0, err
+	}
+	if f, ok := value.(float64); ok {
+		return f, nil
+	}
+	return 0, fmt.Errorf("Data %v is not a number", data)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,247 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestSerialize(t *testing.T) {
+	timestamp := time.Unix(1465000000, 123456789).UTC()
+	reads := plugin.MetricType{
+		Namespace_:   core.NewNamespace("intel", "disk").AddDynamicElement("disk", "disk name").AddStaticElement("reads"),
+		Tags_:        map[string]string{"host": "my host", "rack": ""},
+		Unit_:        "B",
+		Description_: "Bytes read",
+		Timestamp_:   timestamp,
+		Data_:        12,
+	}
+	reads.Namespace()[2].Value = "sda"
+	state := plugin.MetricType{
+		Namespace_: core.NewNamespace("intel", "service", "state"),
+		Tags_:      map[string]string{"name": `say "hi"`},
+		Timestamp_: timestamp,
+		Data_:      `up, "ok"`,
+	}
+	load := plugin.MetricType{
+		Namespace_: core.NewNamespace("intel", "cpu", "load.1"),
+		Timestamp_: timestamp,
+		Data_:      0.5,
+	}
+	serialize := func(format string, metrics []plugin.MetricType, opts SerializeOptions) (string, error) {
+		buf := &bytes.Buffer{}
+		err := Serialize(buf, format, metrics, opts)
+		return buf.String(), err
+	}
+
+	Convey("Given metrics serialized to InfluxDB line protocol", t, func() {
+		Convey("Then they are escaped and typed", func() {
+			out, err := serialize("influx", []plugin.MetricType{reads, state}, SerializeOptions{})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual,
+				`intel/disk/sda/reads,host=my\ host value=12i 1465000000123456789`+"\n"+
+					`intel/service/state,name=say\ "hi" value="up, \"ok\"" 1465000000123456789`+"\n")
+		})
+
+		Convey("Then dynamic elements become tags and precision is applied", func() {
+			out, err := serialize("influx", []plugin.MetricType{reads}, SerializeOptions{ExtractTags: true, Precision: time.Second})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual, `intel/disk/reads,disk=sda,host=my\ host value=12i 1465000000`+"\n")
+		})
+
+		Convey("Then zero timestamp is left out", func() {
+			out, err := serialize("influx", []plugin.MetricType{{Namespace_: core.NewNamespace("intel", "a"), Data_: 1}}, SerializeOptions{})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual, "intel/a value=1i\n")
+		})
+
+		Convey("Then newlines in measurement and tags are replaced", func() {
+			out, err := serialize("influx", []plugin.MetricType{{
+				Namespace_: core.NewNamespace("intel", "a\nb"),
+				Tags_:      map[string]string{"k\n": "v\nw"},
+				Data_:      1,
+			}}, SerializeOptions{})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual, "intel/a_b,k_=v_w value=1i\n")
+		})
+
+		Convey("Then unsupported precision is an error", func() {
+			_, err := serialize("influx", []plugin.MetricType{reads}, SerializeOptions{Precision: 10 * time.Millisecond})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then infinity is an error", func() {
+			_, err := serialize("influx", []plugin.MetricType{{Namespace_: core.NewNamespace("a"), Data_: math.Inf(1)}}, SerializeOptions{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given metrics serialized to Prometheus exposition format", t, func() {
+		second := reads
+		second.Namespace_ = core.NewNamespace("intel", "disk", "sdb", "reads")
+		second.Data_ = true
+
+		Convey("Then metrics of the same name are written together", func() {
+			out, err := serialize("prometheus", []plugin.MetricType{reads, load, second}, SerializeOptions{ExtractTags: true})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual, strings.Join([]string{
+				"# HELP intel_disk_reads Bytes read",
+				"# TYPE intel_disk_reads untyped",
+				`intel_disk_reads{disk="sda",host="my host",rack=""} 12 1465000000123`,
+				"# TYPE intel_cpu_load_1 untyped",
+				"intel_cpu_load_1 0.5 1465000000123",
+				"# HELP intel_disk_sdb_reads Bytes read",
+				"# TYPE intel_disk_sdb_reads untyped",
+				`intel_disk_sdb_reads{host="my host",rack=""} 1 1465000000123`,
+			}, "\n")+"\n")
+		})
+
+		Convey("Then names and labels are sanitized and escaped", func() {
+			So(prometheusName("1st-metric:x", true), ShouldEqual, "_1st_metric:x")
+			So(prometheusName("a:b", false), ShouldEqual, "a_b")
+			out, err := serialize("prometheus", []plugin.MetricType{{
+				Namespace_: core.NewNamespace("a"),
+				Tags_:      map[string]string{"l": "x\\y\n\"z\""},
+				Data_:      math.NaN(),
+			}}, SerializeOptions{})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual, "# TYPE a untyped\n"+`a{l="x\\y\n\"z\""} NaN`+"\n")
+		})
+
+		Convey("Then tags with colliding label names are an error", func() {
+			_, err := serialize("prometheus", []plugin.MetricType{{
+				Namespace_: core.NewNamespace("a"),
+				Tags_:      map[string]string{"a.b": "1", "a_b": "2"},
+				Data_:      1,
+			}}, SerializeOptions{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Cannot serialize /a: tags a.b and a_b are both written as label a_b")
+		})
+
+		Convey("Then string data is an error", func() {
+			_, err := serialize("prometheus", []plugin.MetricType{state}, SerializeOptions{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given metrics serialized to Graphite plaintext", t, func() {
+		Convey("Then elements are joined by dots and tags appended", func() {
+			out, err := serialize("graphite", []plugin.MetricType{reads, load}, SerializeOptions{})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual,
+				"intel.disk.sda.reads;host=my_host 12 1465000000\n"+
+					"intel.cpu.load_1 0.5 1465000000\n")
+		})
+
+		Convey("Then dynamic elements can be extracted", func() {
+			out, err := serialize("graphite", []plugin.MetricType{reads}, SerializeOptions{ExtractTags: true})
+			So(err, ShouldBeNil)
+			So(out, ShouldEqual, "intel.disk.reads;disk=sda;host=my_host 12 1465000000\n")
+		})
+
+		Convey("Then zero timestamp is replaced by current time", func() {
+			before := time.Now().Unix()
+			out, err := serialize("graphite", []plugin.MetricType{{Namespace_: core.NewNamespace("intel", "a"), Data_: 1}}, SerializeOptions{})
+			So(err, ShouldBeNil)
+			fields := strings.Fields(out)
+			So(len(fields), ShouldEqual, 3)
+			ts, err := strconv.ParseInt(fields[2], 10, 64)
+			So(err, ShouldBeNil)
+			So(ts, ShouldBeGreaterThanOrEqualTo, before)
+		})
+	})
+
+	Convey("Given metrics serialized to OpenTSDB JSON", t, func() {
+		Convey("Then points with sanitized names are written", func() {
+			out, err := serialize("opentsdb", []plugin.MetricType{reads, load}, SerializeOptions{ExtractTags: true, Precision: time.Millisecond, DefaultTags: map[string]string{"host": "h1"}})
+			So(err, ShouldBeNil)
+			points := []openTSDBPoint{}
+			So(json.Unmarshal([]byte(out), &points), ShouldBeNil)
+			So(points, ShouldResemble, []openTSDBPoint{
+				{Metric: "intel.disk.reads", Timestamp: 1465000000123, Value: 12, Tags: map[string]string{"disk": "sda", "host": "my_host"}},
+				{Metric: "intel.cpu.load_1", Timestamp: 1465000000123, Value: 0.5, Tags: map[string]string{"host": "h1"}},
+			})
+		})
+
+		Convey("Then unsupported precision is an error", func() {
+			_, err := serialize("opentsdb", []plugin.MetricType{load}, SerializeOptions{Precision: time.Microsecond})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then tags with colliding sanitized keys are an error", func() {
+			_, err := serialize("opentsdb", []plugin.MetricType{{
+				Namespace_: core.NewNamespace("a"),
+				Tags_:      map[string]string{"a b": "1", "a_b": "2"},
+				Data_:      1,
+			}}, SerializeOptions{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Cannot serialize /a: tags a b and a_b are both written as tag a_b")
+		})
+
+		Convey("Then metric without tags is an error", func() {
+			_, err := serialize("opentsdb", []plugin.MetricType{load}, SerializeOptions{})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then zero timestamp is replaced by current time", func() {
+			untimed := reads
+			untimed.Timestamp_ = time.Time{}
+			before := time.Now().Unix()
+			out, err := serialize("opentsdb", []plugin.MetricType{untimed}, SerializeOptions{})
+			So(err, ShouldBeNil)
+			points := []openTSDBPoint{}
+			So(json.Unmarshal([]byte(out), &points), ShouldBeNil)
+			So(points[0].Timestamp, ShouldBeGreaterThanOrEqualTo, before)
+		})
+	})
+
+	Convey("Given metrics serialized to newline-delimited JSON", t, func() {
+		out, err := serialize("ndjson", []plugin.MetricType{reads, state}, SerializeOptions{ExtractTags: true, Precision: time.Millisecond})
+		So(err, ShouldBeNil)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		So(len(lines), ShouldEqual, 2)
+
+		Convey("Then each line holds one metric", func() {
+			metric := map[string]interface{}{}
+			So(json.Unmarshal([]byte(lines[0]), &metric), ShouldBeNil)
+			So(metric["namespace"], ShouldEqual, "/intel/disk/reads")
+			So(metric["tags"], ShouldResemble, map[string]interface{}{"disk": "sda", "host": "my host", "rack": ""})
+			So(metric["unit"], ShouldEqual, "B")
+			So(metric["timestamp"], ShouldEqual, "2016-06-04T00:26:40.123Z")
+			So(metric["data"], ShouldEqual, 12)
+		})
+	})
+
+	Convey("Given unknown format", t, func() {
+		_, err := serialize("xml", nil, SerializeOptions{})
+		So(err, ShouldNotBeNil)
+	})
+}
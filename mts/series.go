@@ -0,0 +1,72 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+// SeriesKey identifies time series of metric by its namespace and tags.
+// Dynamic elements are handled like in ConvertDynamicElements: they are
+// left out of namespace and added as tags, so metric has the same key
+// before and after conversion, e.g. `/intel/disk/reads{disk=sda}`.
+// Separators `/{},=` and `\` within namespace elements, tag keys and values
+// are escaped with `\`, so different series never share a key.
+func SeriesKey(metric plugin.MetricType) string {
+	static, tags := splitSeries(metric)
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = seriesEscaper.Replace(k) + "=" + seriesEscaper.Replace(tags[k])
+	}
+	elements := make([]string, len(static))
+	for i, e := range static {
+		elements[i] = seriesEscaper.Replace(e)
+	}
+	return "/" + strings.Join(elements, "/") + "{" + strings.Join(pairs, ",") + "}"
+}
+
+var seriesEscaper = strings.NewReplacer(`\`, `\\`, `/`, `\/`, `{`, `\{`, `}`, `\}`, `,`, `\,`, `=`, `\=`)
+
+// splitSeries returns static namespace elements of metric and its tags
+// including dynamic elements
+func splitSeries(metric plugin.MetricType) ([]string, map[string]string) {
+	tags := map[string]string{}
+	for k, v := range metric.Tags() {
+		tags[k] = v
+	}
+	_, indexes := metric.Namespace().IsDynamic()
+	static := []string{}
+	for i, nse := range metric.Namespace() {
+		if contains(indexes, i) {
+			tags[nse.Name] = nse.Value
+		} else {
+			static = append(static, nse.Value)
+		}
+	}
+	return static, tags
+}
@@ -0,0 +1,123 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+)
+
+type unit struct {
+	dimension string
+	// factor converts value in unit to base unit of dimension
+	factor float64
+}
+
+// units known to ConvertUnit, names are case sensitive
+var units = map[string]unit{
+	"B":     {"bytes", 1},
+	"byte":  {"bytes", 1},
+	"bytes": {"bytes", 1},
+	"KiB":   {"bytes", 1 << 10},
+	"MiB":   {"bytes", 1 << 20},
+	"GiB":   {"bytes", 1 << 30},
+	"TiB":   {"bytes", 1 << 40},
+	"kB":    {"bytes", 1e3},
+	"KB":    {"bytes", 1e3},
+	"MB":    {"bytes", 1e6},
+	"GB":    {"bytes", 1e9},
+	"TB":    {"bytes", 1e12},
+	"ns":    {"time", 1e-9},
+	"us":    {"time", 1e-6},
+	"µs":    {"time", 1e-6},
+	"ms":    {"time", 1e-3},
+	"s":     {"time", 1},
+	"min":   {"time", 60},
+	"h":     {"time", 3600},
+	"%":     {"ratio", 0.01},
+	"ratio": {"ratio", 1},
+}
+
+// ConvertUnit converts value between units of the same dimension: bytes
+// (B, KiB, MiB, GiB, TiB, kB, MB, GB, TB), time (ns, us, ms, s, min, h)
+// and ratio (%, ratio). Units may have the same suffix like "/s",
+// e.g. "B/s" can be converted to "MiB/s".
+func ConvertUnit(value float64, from, to string) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	fromUnit, fromSuffix := splitUnit(from)
+	toUnit, toSuffix := splitUnit(to)
+	f, fok := units[fromUnit]
+	t, tok := units[toUnit]
+	if !fok || !tok || f.dimension != t.dimension || fromSuffix != toSuffix {
+		return 0, fmt.Errorf("Cannot convert unit %q to %q", from, to)
+	}
+	return value * f.factor / t.factor, nil
+}
+
+func splitUnit(u string) (string, string) {
+	if i := strings.Index(u, "/"); i >= 0 {
+		return u[:i], u[i:]
+	}
+	return u, ""
+}
+
+// ConvertMetricUnit converts numeric data of metric from unit given by
+// Unit_ to unit to. Converted data is float64.
+func ConvertMetricUnit(metric plugin.MetricType, to string) (plugin.MetricType, error) {
+	value, err := Normalize(metric.Data(), NormalizeRules{NumbersAsFloat: true})
+	f, ok := value.(float64)
+	if err != nil || !ok {
+		return metric, fmt.Errorf("Metric %s: value %v is not a number", metric.Namespace().String(), metric.Data())
+	}
+	converted, err := ConvertUnit(f, metric.Unit(), to)
+	if err != nil {
+		return metric, fmt.Errorf("Metric %s: %v", metric.Namespace().String(), err)
+	}
+	metric.Data_ = converted
+	metric.Unit_ = to
+	return metric, nil
+}
+
+// ConvertMetricUnits converts metrics in place according to map of units,
+// e.g. {"B": "MiB", "ns": "ms"} converts all metrics with Unit_ "B" to
+// MiB. Metrics with other units are left unchanged. First error is returned
+// and metric which failed is left unchanged.
+func ConvertMetricUnits(metrics []plugin.MetricType, conversions map[string]string) error {
+	var first error
+	for i, m := range metrics {
+		to, ok := conversions[m.Unit()]
+		if !ok {
+			continue
+		}
+		converted, err := ConvertMetricUnit(m, to)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		metrics[i] = converted
+	}
+	return first
+}
@@ -0,0 +1,101 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mts
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+func TestConvertUnit(t *testing.T) {
+	Convey("Given values in various units", t, func() {
+		Convey("Then they are converted within dimension", func() {
+			cases := []struct {
+				value    float64
+				from, to string
+				expected float64
+			}{
+				{2048, "B", "KiB", 2},
+				{3, "MiB", "bytes", 3 << 20},
+				{1, "GiB", "MiB", 1024},
+				{1500, "B", "kB", 1.5},
+				{1500, "ns", "us", 1.5},
+				{250, "ms", "s", 0.25},
+				{2, "min", "s", 120},
+				{45, "%", "ratio", 0.45},
+				{0.5, "ratio", "%", 50},
+				{1 << 20, "B/s", "MiB/s", 1},
+				{7, "s", "s", 7},
+			}
+			for _, c := range cases {
+				v, err := ConvertUnit(c.value, c.from, c.to)
+				So(err, ShouldBeNil)
+				So(v, ShouldAlmostEqual, c.expected)
+			}
+		})
+
+		Convey("Then conversion between dimensions is an error", func() {
+			_, err := ConvertUnit(1, "B", "s")
+			So(err.Error(), ShouldEqual, `Cannot convert unit "B" to "s"`)
+			_, err = ConvertUnit(1, "B/s", "KiB")
+			So(err, ShouldNotBeNil)
+			_, err = ConvertUnit(1, "furlong", "m")
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given metrics with units", t, func() {
+		metrics := []plugin.MetricType{
+			plugin.MetricType{Namespace_: core.NewNamespace("mem"), Data_: uint64(3 << 20), Unit_: "B"},
+			plugin.MetricType{Namespace_: core.NewNamespace("latency"), Data_: int64(1500000), Unit_: "ns"},
+			plugin.MetricType{Namespace_: core.NewNamespace("load"), Data_: 0.5, Unit_: "ratio"},
+			plugin.MetricType{Namespace_: core.NewNamespace("broken"), Data_: "x", Unit_: "B"},
+		}
+
+		Convey("When units are converted according to map", func() {
+			err := ConvertMetricUnits(metrics, map[string]string{"B": "MiB", "ns": "ms"})
+
+			Convey("Then data and units of matching metrics are converted", func() {
+				So(err.Error(), ShouldEqual, "Metric /broken: value x is not a number")
+				So(metrics[0].Data(), ShouldEqual, 3.0)
+				So(metrics[0].Unit(), ShouldEqual, "MiB")
+				So(metrics[1].Data(), ShouldEqual, 1.5)
+				So(metrics[1].Unit(), ShouldEqual, "ms")
+				So(metrics[2].Data(), ShouldEqual, 0.5)
+				So(metrics[3].Data(), ShouldEqual, "x")
+			})
+		})
+
+		Convey("When single metric is converted", func() {
+			m, err := ConvertMetricUnit(metrics[2], "%")
+
+			Convey("Then it gets new unit", func() {
+				So(err, ShouldBeNil)
+				So(m.Data(), ShouldEqual, 50.0)
+				So(m.Unit(), ShouldEqual, "%")
+				So(metrics[2].Unit(), ShouldEqual, "ratio")
+			})
+		})
+	})
+}
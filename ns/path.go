@@ -0,0 +1,131 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ns
+
+import (
+	"strings"
+
+	"github.com/intelsdi-x/snap/core"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/stack"
+)
+
+// PathElement is an element of Path with metadata of namespace element.
+// Name is set for dynamic elements only.
+type PathElement struct {
+	Value       string
+	Name        string
+	Description string
+}
+
+// Path builds namespace element by element while walking structures,
+// e.g. elements are pushed when walker descends into a field and popped
+// when it returns. Zero value is an empty path. Path is not safe for
+// concurrent use.
+type Path struct {
+	elements stack.Stack[PathElement]
+}
+
+// NewPath creates path starting with given static elements
+func NewPath(elements ...string) *Path {
+	p := &Path{}
+	for _, e := range elements {
+		p.Push(e)
+	}
+	return p
+}
+
+// Push appends static element
+func (p *Path) Push(value string) *Path {
+	return p.PushElement(PathElement{Value: value})
+}
+
+// PushDynamic appends dynamic element (with value "*") of given name and description
+func (p *Path) PushDynamic(name, description string) *Path {
+	return p.PushElement(PathElement{Value: "*", Name: name, Description: description})
+}
+
+// PushElement appends element with metadata
+func (p *Path) PushElement(e PathElement) *Path {
+	p.elements.Push(e)
+	return p
+}
+
+// Pop removes last element, ok is false if path is empty
+func (p *Path) Pop() (PathElement, bool) {
+	return p.elements.Pop()
+}
+
+// Last returns last element without removing it, ok is false if path is empty
+func (p *Path) Last() (PathElement, bool) {
+	return p.elements.Pick()
+}
+
+// Len returns number of elements
+func (p *Path) Len() int {
+	return p.elements.Len()
+}
+
+// Mark returns current length of path, to be passed to Undo
+func (p *Path) Mark() int {
+	return p.Len()
+}
+
+// Undo removes elements pushed since Mark returned mark
+func (p *Path) Undo(mark int) {
+	for p.Len() > mark {
+		p.Pop()
+	}
+}
+
+// Elements returns copy of elements from first to last
+func (p *Path) Elements() []PathElement {
+	elements := p.elements.Snapshot()
+	for i, j := 0, len(elements)-1; i < j; i, j = i+1, j-1 {
+		elements[i], elements[j] = elements[j], elements[i]
+	}
+	return elements
+}
+
+// Strings returns values of elements
+func (p *Path) Strings() []string {
+	elements := p.Elements()
+	values := make([]string, len(elements))
+	for i, e := range elements {
+		values[i] = e.Value
+	}
+	return values
+}
+
+// String returns values of elements joined with '/', like namespaces
+// built by FromCompositeObject
+func (p *Path) String() string {
+	return strings.Join(p.Strings(), "/")
+}
+
+// Namespace returns path as namespace, including names and descriptions of elements
+func (p *Path) Namespace() core.Namespace {
+	elements := p.Elements()
+	namespace := make(core.Namespace, len(elements))
+	for i, e := range elements {
+		namespace[i] = core.NamespaceElement{Value: e.Value, Name: e.Name, Description: e.Description}
+	}
+	return namespace
+}
@@ -0,0 +1,89 @@
+//go:build unit
+// +build unit
+
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ns
+
+import (
+	"testing"
+
+	"github.com/intelsdi-x/snap/core"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPath(t *testing.T) {
+	Convey("Given path with prefix", t, func() {
+		p := NewPath("intel", "mock")
+
+		Convey("When elements are pushed", func() {
+			p.PushDynamic("host", "name of the host").Push("cpu").PushElement(PathElement{Value: "load", Description: "cpu load"})
+
+			Convey("Then path can be snapshotted as strings", func() {
+				So(p.Len(), ShouldEqual, 5)
+				So(p.Strings(), ShouldResemble, []string{"intel", "mock", "*", "cpu", "load"})
+				So(p.String(), ShouldEqual, "intel/mock/*/cpu/load")
+			})
+
+			Convey("Then path can be snapshotted as namespace with metadata", func() {
+				namespace := p.Namespace()
+				So(namespace.String(), ShouldEqual, "/intel/mock/*/cpu/load")
+				So(namespace[2], ShouldResemble, core.NamespaceElement{Value: "*", Name: "host", Description: "name of the host"})
+				So(namespace[4].Description, ShouldEqual, "cpu load")
+				dynamic, _ := namespace.IsDynamic()
+				So(dynamic, ShouldBeTrue)
+			})
+
+			Convey("Then last element can be popped", func() {
+				e, ok := p.Pop()
+				So(ok, ShouldBeTrue)
+				So(e.Value, ShouldEqual, "load")
+				e, _ = p.Last()
+				So(e.Value, ShouldEqual, "cpu")
+				So(p.String(), ShouldEqual, "intel/mock/*/cpu")
+			})
+		})
+
+		Convey("When elements pushed after mark are undone", func() {
+			mark := p.Mark()
+			p.Push("a").Push("b")
+			p.Undo(mark)
+
+			Convey("Then path is restored", func() {
+				So(p.Strings(), ShouldResemble, []string{"intel", "mock"})
+				p.Undo(0)
+				So(p.Len(), ShouldEqual, 0)
+				_, ok := p.Pop()
+				So(ok, ShouldBeFalse)
+				So(p.Namespace(), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given zero value path", t, func() {
+		var p Path
+
+		Convey("Then elements can be pushed", func() {
+			p.Push("intel").PushDynamic("host", "host name")
+			So(p.String(), ShouldEqual, "intel/*")
+			So(p.Len(), ShouldEqual, 2)
+		})
+	})
+}
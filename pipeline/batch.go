@@ -0,0 +1,118 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"time"
+)
+
+// Batch groups items into []interface{} slices. Batch is emitted when it
+// holds MaxSize items or when MaxAge passed since its first item arrived,
+// whichever comes first, and when input is closed. Zero MaxSize or MaxAge
+// disables respective trigger.
+// If Pool is set, batch buffers are taken from it, so downstream stage may
+// give them back with Pool.Recycle when it is done with a batch.
+// If State is set, pending batch is kept in it, so it can be checkpointed
+// and restored batch is continued by Run.
+// Clock defaults to SystemClock.
+type Batch struct {
+	MaxSize int
+	MaxAge  time.Duration
+	Pool    *BatchPool
+	State   *BatchState
+	Clock   Clock
+}
+
+func (self Batch) Run(input, output Pipe) {
+	clock := clockOrSystem(self.Clock)
+	var timer <-chan time.Time
+	batch := self.State.get()
+	if len(batch) == 0 {
+		batch = nil
+	} else if self.MaxAge > 0 {
+		timer = clock.After(self.MaxAge)
+	}
+
+	flush := func() {
+		self.State.set(nil)
+		if len(batch) > 0 {
+			output <- batch
+		}
+		batch = nil
+		timer = nil
+	}
+
+	for {
+		select {
+		case v, ok := <-input:
+			if !ok {
+				flush()
+				close(output)
+				return
+			}
+			if batch == nil {
+				batch = self.Pool.get(self.MaxSize)
+				if self.MaxAge > 0 {
+					timer = clock.After(self.MaxAge)
+				}
+			}
+			batch = append(batch, v)
+			self.State.set(batch)
+			if self.MaxSize > 0 && len(batch) >= self.MaxSize {
+				flush()
+			}
+		case <-timer:
+			flush()
+		}
+	}
+}
+
+// BatchPool keeps buffers of batches for reuse
+type BatchPool struct {
+	free chan []interface{}
+}
+
+// NewBatchPool creates pool which keeps at most size free buffers
+func NewBatchPool(size int) *BatchPool {
+	return &BatchPool{free: make(chan []interface{}, size)}
+}
+
+// Recycle gives batch buffer back to pool. Batch must not be used afterwards.
+func (p *BatchPool) Recycle(batch []interface{}) {
+	// drop references, so recycled buffer does not keep items alive
+	for i := range batch {
+		batch[i] = nil
+	}
+	select {
+	case p.free <- batch[:0]:
+	default:
+	}
+}
+
+func (p *BatchPool) get(size int) []interface{} {
+	if p != nil {
+		select {
+		case batch := <-p.free:
+			return batch
+		default:
+		}
+	}
+	return make([]interface{}, 0, size)
+}
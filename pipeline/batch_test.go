@@ -0,0 +1,91 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBatch(t *testing.T) {
+	Convey("Given batch stage limited by size", t, func() {
+		items := drain(Pipeline(feed(1, 2, 3, 4, 5), Batch{MaxSize: 2}))
+
+		Convey("Then full batches are emitted and the rest is flushed on close", func() {
+			So(items, ShouldResemble, []interface{}{
+				[]interface{}{1, 2},
+				[]interface{}{3, 4},
+				[]interface{}{5},
+			})
+		})
+	})
+
+	Convey("Given batch stage limited by size and age", t, func() {
+		clock := newFakeClock()
+		input := make(Pipe)
+		output := make(Pipe)
+		go Batch{MaxSize: 3, MaxAge: time.Second, Clock: clock}.Run(input, output)
+
+		Convey("When items arrive slower than max age", func() {
+			input <- 1
+			input <- 2
+			clock.WaitForTimers(1)
+			clock.Advance(time.Second)
+
+			Convey("Then batch is emitted when it gets too old", func() {
+				So(<-output, ShouldResemble, []interface{}{1, 2})
+
+				input <- 3
+				input <- 4
+				input <- 5
+				So(<-output, ShouldResemble, []interface{}{3, 4, 5})
+
+				close(input)
+				So(drain(output), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given batch stage with buffer pool", t, func() {
+		pool := NewBatchPool(1)
+		input := make(Pipe)
+		output := Pipeline(input, Batch{MaxSize: 2, Pool: pool})
+
+		Convey("When downstream recycles a batch", func() {
+			input <- "a"
+			input <- "b"
+			first := (<-output).([]interface{})
+			So(first, ShouldResemble, []interface{}{"a", "b"})
+			pool.Recycle(first)
+
+			input <- "c"
+			input <- "d"
+			second := (<-output).([]interface{})
+			close(input)
+
+			Convey("Then its buffer is reused for the next batch", func() {
+				So(second, ShouldResemble, []interface{}{"c", "d"})
+				So(&first[:1][0], ShouldEqual, &second[0])
+			})
+		})
+	})
+}
@@ -0,0 +1,220 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/ns"
+	"github.com/intelsdi-x/snap-plugin-utilities/source"
+)
+
+// Definition describes pipeline as data: command which produces input,
+// ordered processing stages and mapping of resulting fields to namespaces.
+//
+// Example in YAML:
+//
+//	source:
+//	  command: iostat
+//	  args: ["-dx", "1"]
+//	stages:
+//	  - type: split_fields
+//	    params: {header_pattern: "^Device"}
+//	  - type: parse_numbers
+//	output:
+//	  - field: r/s
+//	    namespace: /intel/iostat/{Device}/reads
+type Definition struct {
+	Source SourceDefinition   `json:"source" yaml:"source"`
+	Stages []StageDefinition  `json:"stages" yaml:"stages"`
+	Output []OutputDefinition `json:"output" yaml:"output"`
+}
+
+// SourceDefinition describes external command feeding the pipeline
+type SourceDefinition struct {
+	Command string   `json:"command" yaml:"command"`
+	Args    []string `json:"args" yaml:"args"`
+}
+
+// StageDefinition describes single stage, Type is name of registered Factory
+type StageDefinition struct {
+	Type   string `json:"type" yaml:"type"`
+	Params Params `json:"params" yaml:"params"`
+}
+
+// OutputDefinition maps field of pipeline items to namespace.
+// Namespace may contain `{field}` placeholders which are replaced with
+// (sanitized) values of item fields, e.g. /intel/iostat/{Device}/reads.
+type OutputDefinition struct {
+	Field     string `json:"field" yaml:"field"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+}
+
+var placeholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// ParseDefinition decodes pipeline definition from JSON or YAML document
+// and validates it. Documents starting with '{' are decoded as JSON.
+func ParseDefinition(data []byte) (*Definition, error) {
+	d := &Definition{}
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		err = json.Unmarshal(data, d)
+	} else {
+		err = yaml.Unmarshal(data, d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode pipeline definition: %v", err)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// LoadDefinition reads pipeline definition from file, see ParseDefinition
+func LoadDefinition(path string) (*Definition, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDefinition(data)
+}
+
+// Validate checks that source command is set, all stages are known and
+// accept their parameters and output mapping is complete.
+func (d *Definition) Validate() error {
+	if d.Source.Command == "" {
+		return fmt.Errorf("Invalid pipeline definition: source command is empty")
+	}
+	if _, err := d.Processors(); err != nil {
+		return err
+	}
+	for i, out := range d.Output {
+		if out.Field == "" || out.Namespace == "" {
+			return fmt.Errorf("Invalid pipeline definition: output #%d requires field and namespace", i)
+		}
+		for _, part := range strings.Split(placeholder.ReplaceAllString(out.Namespace, "x"), "/") {
+			if err := ns.ValidateMetricNamespacePart(part); err != nil {
+				return fmt.Errorf("Invalid pipeline definition: output #%d: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Processors creates processors for all stages in order
+func (d *Definition) Processors() ([]Processor, error) {
+	processors := make([]Processor, len(d.Stages))
+	for i, stage := range d.Stages {
+		proc, err := NewProcessor(stage.Type, stage.Params)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pipeline definition: stage #%d (%s): %v", i, stage.Type, err)
+		}
+		processors[i] = proc
+	}
+	return processors, nil
+}
+
+// Build chains processors of all stages starting from given input pipe.
+// It returns last Pipe in Pipeline.
+func (d *Definition) Build(input Pipe) (Pipe, error) {
+	processors, err := d.Processors()
+	if err != nil {
+		return nil, err
+	}
+	return Pipeline(input, processors...), nil
+}
+
+// Start runs source command and feeds its output through the pipeline.
+// Command errors are sent to ech. It returns last Pipe in Pipeline.
+func (d *Definition) Start(ech chan error) (Pipe, error) {
+	input := make(Pipe)
+	output, err := d.Build(input)
+	if err != nil {
+		return nil, err
+	}
+	s := &source.Source{Command: d.Source.Command, Args: d.Source.Args}
+	go s.Generate(input, ech)
+	return output, nil
+}
+
+// MapOutput translates pipeline item to namespace and value pairs according
+// to output definitions. Item is a map of fields or a map of such maps
+// (as emitted by CollectMap). Fields missing in item are skipped.
+func (d *Definition) MapOutput(item interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	fields, ok := toFields(item)
+	if !ok {
+		return result
+	}
+	nested := false
+	for _, v := range fields {
+		if f, ok := toFields(v); ok {
+			nested = true
+			d.mapFields(f, result)
+		}
+	}
+	if !nested {
+		d.mapFields(fields, result)
+	}
+	return result
+}
+
+func (d *Definition) mapFields(fields map[string]interface{}, result map[string]interface{}) {
+	for _, out := range d.Output {
+		value, ok := fields[out.Field]
+		if !ok {
+			continue
+		}
+		complete := true
+		namespace := placeholder.ReplaceAllStringFunc(out.Namespace, func(m string) string {
+			v, ok := fields[m[1:len(m)-1]]
+			if !ok {
+				complete = false
+				return m
+			}
+			return ns.ReplaceNotAllowedCharsInNamespacePart(fmt.Sprint(v))
+		})
+		if complete {
+			result[namespace] = value
+		}
+	}
+}
+
+func toFields(item interface{}) (map[string]interface{}, bool) {
+	switch m := item.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[string]string:
+		fields := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			fields[k] = v
+		}
+		return fields, true
+	}
+	return nil, false
+}
@@ -0,0 +1,138 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const yamlDefinition = `
+source:
+  command: iostat
+  args: ["-dx"]
+stages:
+  - type: split_fields
+    params:
+      header_pattern: "^Device"
+  - type: parse_numbers
+    params:
+      fields: [reads]
+  - type: collect_map
+    params: {key: Device}
+output:
+  - field: reads
+    namespace: /intel/iostat/{Device}/reads
+`
+
+const jsonDefinition = `{
+	"source": {"command": "cat", "args": ["/proc/meminfo"]},
+	"stages": [
+		{"type": "key_value", "params": {"separator": ":"}},
+		{"type": "skip", "params": {"count": 1}}
+	],
+	"output": [{"field": "MemFree", "namespace": "/intel/mem/free"}]
+}`
+
+func TestParseDefinition(t *testing.T) {
+	Convey("Given YAML pipeline definition", t, func() {
+		d, err := ParseDefinition([]byte(yamlDefinition))
+
+		Convey("Then it is decoded", func() {
+			So(err, ShouldBeNil)
+			So(d.Source.Command, ShouldEqual, "iostat")
+			So(d.Source.Args, ShouldResemble, []string{"-dx"})
+			So(len(d.Stages), ShouldEqual, 3)
+		})
+
+		Convey("When pipeline is built and fed with command output", func() {
+			input := feed("Device reads", "sda 1", "sd/b 2", "Device reads", "sdc x")
+			output, err := d.Build(input)
+			So(err, ShouldBeNil)
+			items := drain(output)
+
+			Convey("Then output is mapped to namespaces", func() {
+				So(len(items), ShouldEqual, 1)
+				So(d.MapOutput(items[0]), ShouldResemble, map[string]interface{}{
+					"/intel/iostat/sda/reads":  int64(1),
+					"/intel/iostat/sd_b/reads": int64(2),
+					"/intel/iostat/sdc/reads":  "x",
+				})
+			})
+		})
+	})
+
+	Convey("Given JSON pipeline definition", t, func() {
+		d, err := ParseDefinition([]byte(jsonDefinition))
+		So(err, ShouldBeNil)
+
+		Convey("Then stages get their parameters", func() {
+			procs, err := d.Processors()
+			So(err, ShouldBeNil)
+			So(procs, ShouldResemble, []Processor{KeyValue{Separator: ":"}, Skip{Count: 1}})
+		})
+
+		Convey("Then flat items are mapped to namespaces", func() {
+			So(d.MapOutput(map[string]string{"MemFree": "10 kB"}), ShouldResemble,
+				map[string]interface{}{"/intel/mem/free": "10 kB"})
+			So(d.MapOutput(map[string]string{"MemTotal": "10 kB"}), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestDefinitionValidation(t *testing.T) {
+	Convey("Given invalid pipeline definitions", t, func() {
+		cases := map[string]string{
+			`{"stages": []}`: "source command is empty",
+			`{"source": {"command": "ls"}, "stages": [{"type": "nope"}]}`:                               `stage #0 (nope): Unknown stage "nope"`,
+			`{"source": {"command": "ls"}, "stages": [{"type": "skip", "params": {"cnt": 1}}]}`:         `stage #0 (skip): Unknown parameter "cnt"`,
+			`{"source": {"command": "ls"}, "stages": [{"type": "skip", "params": {"count": "x"}}]}`:     `stage #0 (skip): Parameter "count" must be an integer`,
+			`{"source": {"command": "ls"}, "stages": [{"type": "regexp", "params": {"pattern": "("}}]}`: `stage #0 (regexp): Parameter "pattern" is not a valid regular expression`,
+			`{"source": {"command": "ls"}, "stages": [{"type": "sections"}]}`:                           `stage #0 (sections): Parameter "header" is required`,
+			`{"source": {"command": "ls"}, "output": [{"field": "a"}]}`:                                 "output #0 requires field and namespace",
+			`{"source": {"command": "ls"}, "output": [{"field": "a", "namespace": "/a b/c"}]}`:          "output #0: Namespace contains not allowed chars",
+			"source: [": "Cannot decode pipeline definition",
+		}
+
+		Convey("Then each of them gives clear error", func() {
+			for doc, msg := range cases {
+				_, err := ParseDefinition([]byte(doc))
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, msg)
+			}
+		})
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("Given custom processor factory", t, func() {
+		Register("test_passthrough", func(p Params) (Processor, error) {
+			return Nonblocking{}, p.Check()
+		})
+
+		Convey("Then it is listed and can be created by name", func() {
+			So(Registered(), ShouldContain, "test_passthrough")
+			proc, err := NewProcessor("test_passthrough", nil)
+			So(err, ShouldBeNil)
+			So(proc, ShouldResemble, Nonblocking{})
+		})
+	})
+}
@@ -0,0 +1,328 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intelsdi-x/snap/control/plugin"
+	"github.com/intelsdi-x/snap/core"
+)
+
+// Stage states reported in Stats
+const (
+	StateIdle          = "idle"
+	StateWaitingInput  = "waiting_input"
+	StateProcessing    = "processing"
+	StateBlockedOnSend = "blocked_on_send"
+	StateFinished      = "finished"
+)
+
+const latencyBucketPrefix = "le_"
+
+// LatencyBuckets are upper bounds of latency histogram buckets
+var LatencyBuckets = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts observations in LatencyBuckets.
+// Counts has one more element than Bounds for observations above the last bound.
+type Histogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Count  uint64
+	Sum    time.Duration
+}
+
+func newHistogram() Histogram {
+	return Histogram{Bounds: LatencyBuckets, Counts: make([]uint64, len(LatencyBuckets)+1)}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Bounds) && d > h.Bounds[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Stats is a snapshot of stage instrumentation.
+// Latency is measured from handing an item to the stage until the stage
+// emits its next item, so items dropped by filters are not observed.
+// As the stage is observed from outside, an item emitted while next one is
+// already handed over is not observed either, so Latency is a sample.
+// QueueDepth is the number of items waiting in front of the stage.
+type Stats struct {
+	Name           string
+	In             uint64
+	Out            uint64
+	Latency        Histogram
+	BlockedReceive time.Duration
+	BlockedSend    time.Duration
+	QueueDepth     int
+	State          string
+}
+
+// Instrumented wraps Processor and gathers its Stats
+type Instrumented struct {
+	Name      string
+	Processor Processor
+
+	mutex     sync.Mutex
+	stats     Stats
+	input     Pipe
+	started   bool
+	receiving bool
+	holding   bool
+	sending   bool
+	pending   time.Time
+	running   int
+}
+
+// Instrument wraps processor with instrumentation under given stage name
+func Instrument(name string, proc Processor) *Instrumented {
+	return &Instrumented{Name: name, Processor: proc}
+}
+
+// Run implements Processor, it runs wrapped processor between two
+// forwarding goroutines which measure traffic in and out of the stage.
+func (self *Instrumented) Run(input, output Pipe) {
+	self.mutex.Lock()
+	self.stats = Stats{Name: self.Name, Latency: newHistogram()}
+	self.input = input
+	self.started = true
+	self.running = 2
+	self.mutex.Unlock()
+
+	innerIn := make(Pipe)
+	innerOut := make(Pipe)
+	go self.Processor.Run(innerIn, innerOut)
+	go self.forwardIn(input, innerIn)
+	self.forwardOut(innerOut, output)
+}
+
+func (self *Instrumented) forwardIn(input, innerIn Pipe) {
+	for {
+		self.mutex.Lock()
+		self.receiving = true
+		self.mutex.Unlock()
+
+		start := time.Now()
+		v, ok := <-input
+
+		self.mutex.Lock()
+		self.stats.BlockedReceive += time.Since(start)
+		self.receiving = false
+		self.holding = ok
+		self.mutex.Unlock()
+		if !ok {
+			break
+		}
+
+		innerIn <- v
+
+		self.mutex.Lock()
+		self.holding = false
+		self.stats.In++
+		self.pending = time.Now()
+		self.mutex.Unlock()
+	}
+	close(innerIn)
+	self.finish()
+}
+
+func (self *Instrumented) forwardOut(innerOut, output Pipe) {
+	for v := range innerOut {
+		self.mutex.Lock()
+		if !self.pending.IsZero() {
+			self.stats.Latency.observe(time.Since(self.pending))
+			self.pending = time.Time{}
+		}
+		self.sending = true
+		self.mutex.Unlock()
+
+		start := time.Now()
+		output <- v
+
+		self.mutex.Lock()
+		self.stats.BlockedSend += time.Since(start)
+		self.stats.Out++
+		self.sending = false
+		self.mutex.Unlock()
+	}
+	close(output)
+	self.finish()
+}
+
+func (self *Instrumented) finish() {
+	self.mutex.Lock()
+	self.running--
+	self.mutex.Unlock()
+}
+
+// Stats returns snapshot of stage instrumentation
+func (self *Instrumented) Stats() Stats {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	stats := self.stats
+	stats.Name = self.Name
+	stats.State = self.state()
+	stats.Latency.Counts = append([]uint64(nil), self.stats.Latency.Counts...)
+	stats.QueueDepth = len(self.input)
+	if self.holding {
+		stats.QueueDepth++
+	}
+	return stats
+}
+
+// state tells what stage is doing, blocking on send takes precedence
+// as it means that the stall is further down the pipeline
+func (self *Instrumented) state() string {
+	switch {
+	case !self.started:
+		return StateIdle
+	case self.running == 0:
+		return StateFinished
+	case self.sending:
+		return StateBlockedOnSend
+	case self.receiving:
+		return StateWaitingInput
+	}
+	return StateProcessing
+}
+
+// Instruments is a set of instrumented stages of one pipeline
+type Instruments []*Instrumented
+
+// InstrumentedPipeline works as Pipeline, but wraps each processor with
+// instrumentation. Stages are named after their position and type,
+// e.g. `1_stringcontains`. It returns last Pipe in Pipeline and stage instruments.
+func InstrumentedPipeline(input Pipe, processors ...Processor) (Pipe, Instruments) {
+	instruments := make(Instruments, len(processors))
+	wrapped := make([]Processor, len(processors))
+	for i, proc := range processors {
+		instruments[i] = Instrument(stageName(i, proc), proc)
+		wrapped[i] = instruments[i]
+	}
+	return Pipeline(input, wrapped...), instruments
+}
+
+// stageName names stage after its position and processor type,
+// nil processor is named "nil"
+func stageName(i int, proc Processor) string {
+	if proc == nil {
+		return fmt.Sprintf("%d_nil", i)
+	}
+	t := reflect.TypeOf(proc)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return fmt.Sprintf("%d_%s", i, strings.ToLower(t.Name()))
+}
+
+// Stats returns snapshots of all stages
+func (in Instruments) Stats() []Stats {
+	stats := make([]Stats, len(in))
+	for i, stage := range in {
+		stats[i] = stage.Stats()
+	}
+	return stats
+}
+
+// Metrics returns stage statistics as metrics which collector can publish
+// about itself. Namespaces are built as prefix/[stage]/metric, where stage
+// is a dynamic element holding stage name.
+func (in Instruments) Metrics(prefix ...string) []plugin.MetricType {
+	now := time.Now()
+	metrics := []plugin.MetricType{}
+	for _, stats := range in.Stats() {
+		for _, m := range statsValues(stats) {
+			namespace := stageNamespace(prefix, m.name)
+			namespace[len(prefix)].Value = stats.Name
+			metrics = append(metrics, plugin.MetricType{
+				Namespace_: namespace,
+				Data_:      m.value,
+				Unit_:      m.unit,
+				Timestamp_: now,
+				Tags_:      map[string]string{},
+			})
+		}
+	}
+	return metrics
+}
+
+// MetricTypes returns catalog of instrumentation metrics, useful in GetMetricTypes
+func (in Instruments) MetricTypes(prefix ...string) []plugin.MetricType {
+	metrics := []plugin.MetricType{}
+	for _, m := range statsValues(Stats{Latency: newHistogram()}) {
+		metrics = append(metrics, plugin.MetricType{
+			Namespace_:   stageNamespace(prefix, m.name),
+			Unit_:        m.unit,
+			Description_: m.description,
+		})
+	}
+	return metrics
+}
+
+func stageNamespace(prefix []string, name []string) core.Namespace {
+	return core.NewNamespace(prefix...).
+		AddDynamicElement("stage", "pipeline stage name").
+		AddStaticElements(name...)
+}
+
+type statsValue struct {
+	name        []string
+	value       interface{}
+	unit        string
+	description string
+}
+
+func statsValues(s Stats) []statsValue {
+	values := []statsValue{
+		{[]string{"items_in"}, s.In, "count", "items received by stage"},
+		{[]string{"items_out"}, s.Out, "count", "items emitted by stage"},
+		{[]string{"queue_depth"}, s.QueueDepth, "count", "items waiting in front of stage"},
+		{[]string{"blocked_receive"}, s.BlockedReceive.Nanoseconds(), "ns", "time spent waiting for input"},
+		{[]string{"blocked_send"}, s.BlockedSend.Nanoseconds(), "ns", "time spent blocked on sending output"},
+		{[]string{"latency", "count"}, s.Latency.Count, "count", "number of latency observations"},
+		{[]string{"latency", "sum"}, s.Latency.Sum.Nanoseconds(), "ns", "sum of processing latencies"},
+	}
+	// buckets are cumulative like in Prometheus histograms
+	var cumulative uint64
+	for i, bound := range s.Latency.Bounds {
+		cumulative += s.Latency.Counts[i]
+		values = append(values, statsValue{
+			[]string{"latency", latencyBucketPrefix + strings.Replace(bound.String(), "µ", "u", -1)},
+			cumulative, "count", "latency observations up to " + bound.String()})
+	}
+	return values
+}
@@ -0,0 +1,151 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func waitForState(stage *Instrumented, state string) Stats {
+	for i := 0; i < 1000; i++ {
+		if stats := stage.Stats(); stats.State == state {
+			return stats
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return stage.Stats()
+}
+
+func waitForIn(stage *Instrumented, in uint64) Stats {
+	for i := 0; i < 1000; i++ {
+		if stats := stage.Stats(); stats.In == in {
+			return stats
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return stage.Stats()
+}
+
+func TestInstrumentedPipeline(t *testing.T) {
+	Convey("Given instrumented pipeline", t, func() {
+		input := make(Pipe)
+		output, instruments := InstrumentedPipeline(input, StringContains{Str: "a"}, Skip{Count: 1})
+
+		Convey("Then stages are named after position and type", func() {
+			So(len(instruments), ShouldEqual, 2)
+			So(instruments[0].Name, ShouldEqual, "0_stringcontains")
+			So(instruments[1].Name, ShouldEqual, "1_skip")
+		})
+
+		Convey("When output is not consumed", func() {
+			input <- "a1"
+			input <- "b1"
+			input <- "a2"
+
+			Convey("Then last stage is reported as blocked on send", func() {
+				waitForIn(instruments[1], 2)
+				stats := waitForState(instruments[1], StateBlockedOnSend)
+				So(stats.State, ShouldEqual, StateBlockedOnSend)
+				So(stats.In, ShouldEqual, 2)
+				So(stats.Out, ShouldEqual, 0)
+				So(waitForIn(instruments[0], 3).In, ShouldEqual, 3)
+			})
+
+			Convey("When pipeline is drained and closed", func() {
+				close(input)
+				items := drain(output)
+
+				Convey("Then counters and histograms are complete", func() {
+					So(items, ShouldResemble, []interface{}{"a2"})
+					first := waitForState(instruments[0], StateFinished)
+					So(first.In, ShouldEqual, 3)
+					So(first.Out, ShouldEqual, 2)
+					So(first.Latency.Count, ShouldBeBetweenOrEqual, 1, first.Out)
+					So(len(first.Latency.Counts), ShouldEqual, len(LatencyBuckets)+1)
+					So(first.BlockedSend, ShouldBeGreaterThan, 0)
+					second := waitForState(instruments[1], StateFinished)
+					So(second.In, ShouldEqual, 2)
+					So(second.Out, ShouldEqual, 1)
+					So(second.QueueDepth, ShouldEqual, 0)
+				})
+			})
+		})
+	})
+}
+
+func TestStageName(t *testing.T) {
+	Convey("Given nil processor", t, func() {
+		Convey("Then stage name uses nil placeholder", func() {
+			So(stageName(2, nil), ShouldEqual, "2_nil")
+		})
+
+		Convey("Then it is described as nil", func() {
+			name, typ := describe(nil)
+			So(name, ShouldEqual, "nil")
+			So(typ, ShouldEqual, "nil")
+		})
+	})
+}
+
+func TestHistogram(t *testing.T) {
+	Convey("Given latency histogram", t, func() {
+		h := newHistogram()
+		h.observe(5 * time.Microsecond)
+		h.observe(time.Millisecond)
+		h.observe(time.Minute)
+
+		Convey("Then observations land in proper buckets", func() {
+			So(h.Count, ShouldEqual, 3)
+			So(h.Counts[0], ShouldEqual, 1)
+			So(h.Counts[2], ShouldEqual, 1)
+			So(h.Counts[len(LatencyBuckets)], ShouldEqual, 1)
+			So(h.Sum, ShouldEqual, time.Minute+time.Millisecond+5*time.Microsecond)
+		})
+	})
+}
+
+func TestInstrumentsMetrics(t *testing.T) {
+	Convey("Given finished instrumented pipeline", t, func() {
+		output, instruments := InstrumentedPipeline(feed("x", "y"), Collect{})
+		drain(output)
+		waitForState(instruments[0], StateFinished)
+
+		Convey("Then statistics are exposed as metrics", func() {
+			metrics := instruments.Metrics("intel", "mycollector", "pipeline")
+			values := map[string]interface{}{}
+			for _, m := range metrics {
+				So(m.Namespace()[3].Name, ShouldEqual, "stage")
+				values[m.Namespace().String()] = m.Data()
+			}
+			So(values["/intel/mycollector/pipeline/0_collect/items_in"], ShouldEqual, uint64(2))
+			So(values["/intel/mycollector/pipeline/0_collect/items_out"], ShouldEqual, uint64(1))
+			So(values["/intel/mycollector/pipeline/0_collect/latency/le_10s"], ShouldEqual, uint64(1))
+		})
+
+		Convey("Then catalog has the same metrics with dynamic stage", func() {
+			catalog := instruments.MetricTypes("intel", "mycollector", "pipeline")
+			So(len(catalog), ShouldEqual, len(instruments.Metrics("intel", "mycollector", "pipeline")))
+			So(catalog[0].Namespace().String(), ShouldEqual, "/intel/mycollector/pipeline/*/items_in")
+		})
+	})
+}
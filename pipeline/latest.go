@@ -0,0 +1,103 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry is a value stored in Latest together with its arrival time
+type Entry struct {
+	Value interface{}
+	Time  time.Time
+}
+
+// Latest keeps the last value per key of items passing through it,
+// e.g. newest sample per device, and lets other goroutines (like
+// CollectMetrics) read them safely. Values older than MaxAge are
+// treated as missing, zero MaxAge means values never expire.
+// If KeyFunc is nil all items share one key "".
+// Clock defaults to SystemClock.
+type Latest struct {
+	KeyFunc KeyFunc
+	MaxAge  time.Duration
+	Clock   Clock
+
+	mutex  sync.RWMutex
+	values map[string]Entry
+}
+
+// NewLatest creates latest value cache with given key function and max age
+func NewLatest(keyFunc KeyFunc, maxAge time.Duration) *Latest {
+	return &Latest{KeyFunc: keyFunc, MaxAge: maxAge}
+}
+
+// Run implements Processor, it stores each item and passes it on
+func (self *Latest) Run(input, output Pipe) {
+	clock := clockOrSystem(self.Clock)
+	for v := range input {
+		key := ""
+		if self.KeyFunc != nil {
+			key = self.KeyFunc(v)
+		}
+		self.mutex.Lock()
+		if self.values == nil {
+			self.values = map[string]Entry{}
+		}
+		self.values[key] = Entry{Value: v, Time: clock.Now()}
+		self.mutex.Unlock()
+		output <- v
+	}
+	close(output)
+}
+
+// Get returns the last value stored under key, if it has not expired
+func (self *Latest) Get(key string) (Entry, bool) {
+	now := clockOrSystem(self.Clock).Now()
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	e, ok := self.values[key]
+	if !ok || self.expired(e, now) {
+		return Entry{}, false
+	}
+	return e, true
+}
+
+// Snapshot returns copy of all values which have not expired.
+// Expired values are removed.
+func (self *Latest) Snapshot() map[string]Entry {
+	now := clockOrSystem(self.Clock).Now()
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	snapshot := make(map[string]Entry, len(self.values))
+	for key, e := range self.values {
+		if self.expired(e, now) {
+			delete(self.values, key)
+			continue
+		}
+		snapshot[key] = e
+	}
+	return snapshot
+}
+
+func (self *Latest) expired(e Entry, now time.Time) bool {
+	return self.MaxAge > 0 && now.Sub(e.Time) > self.MaxAge
+}
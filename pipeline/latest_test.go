@@ -0,0 +1,103 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLatest(t *testing.T) {
+	Convey("Given latest value cache per device with max age", t, func() {
+		clock := newFakeClock()
+		device := func(item interface{}) string { return strings.Fields(item.(string))[0] }
+		latest := NewLatest(device, time.Minute)
+		latest.Clock = clock
+		s := newStepper(clock, latest)
+
+		Convey("When samples of several devices flow through it", func() {
+			start := clock.Now()
+			s.send("sda 1", "sdb 1")
+			clock.Advance(45 * time.Second)
+			s.send("sda 2")
+
+			Convey("Then newest value per device is kept with its arrival time", func() {
+				e, ok := latest.Get("sda")
+				So(ok, ShouldBeTrue)
+				So(e.Value, ShouldEqual, "sda 2")
+				So(e.Time, ShouldResemble, start.Add(45*time.Second))
+				So(latest.Snapshot(), ShouldResemble, map[string]Entry{
+					"sda": {Value: "sda 2", Time: start.Add(45 * time.Second)},
+					"sdb": {Value: "sdb 1", Time: start},
+				})
+			})
+
+			Convey("Then values expire after max age", func() {
+				clock.Advance(30 * time.Second)
+				_, ok := latest.Get("sdb")
+				So(ok, ShouldBeFalse)
+				So(latest.Snapshot(), ShouldContainKey, "sda")
+				So(latest.Snapshot(), ShouldNotContainKey, "sdb")
+			})
+
+			Convey("Then items are passed on", func() {
+				So(s.close(), ShouldResemble, []interface{}{"sda 1", "sdb 1", "sda 2"})
+			})
+		})
+	})
+
+	Convey("Given latest value cache without key function", t, func() {
+		latest := &Latest{}
+		drain(Pipeline(feed(1, 2, 3), latest))
+
+		Convey("Then the last item is kept forever", func() {
+			e, ok := latest.Get("")
+			So(ok, ShouldBeTrue)
+			So(e.Value, ShouldEqual, 3)
+		})
+	})
+}
+
+func TestLastValue(t *testing.T) {
+	Convey("Given LastValue read while items flow", t, func() {
+		last := &LastValue{}
+		input := make(Pipe)
+		output := Pipeline(input, last)
+		done := make(chan bool)
+		go func() {
+			for range output {
+				last.Last()
+			}
+			done <- true
+		}()
+		for i := 0; i < 100; i++ {
+			input <- i
+		}
+		close(input)
+		<-done
+
+		Convey("Then it holds the last item", func() {
+			So(last.Last(), ShouldEqual, 99)
+		})
+	})
+}
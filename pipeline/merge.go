@@ -0,0 +1,165 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// KeyFunc returns routing key of pipeline item
+type KeyFunc func(item interface{}) string
+
+// Merge joins items from all given pipes into one pipe.
+// Returned pipe is closed when all input pipes are closed.
+func Merge(pipes ...Pipe) Pipe {
+	outPipe := make(Pipe)
+	record(KindMerge, "merge", "", pipes, []Pipe{outPipe})
+
+	var wg sync.WaitGroup
+	wg.Add(len(pipes))
+	for _, p := range pipes {
+		go func(input Pipe) {
+			for v := range input {
+				outPipe <- v
+			}
+			wg.Done()
+		}(p)
+	}
+
+	go func() {
+		wg.Wait()
+		close(outPipe)
+	}()
+
+	return outPipe
+}
+
+// Partition routes items to one of n pipes based on item key.
+// Items with the same key always land in the same pipe, so e.g. all
+// samples of one device are handled by one consumer.
+// n lower than 1 is treated as 1, so all items land in a single pipe.
+// All returned pipes are closed when input pipe is closed.
+func (p Pipe) Partition(keyFunc KeyFunc, n int) []Pipe {
+	if n < 1 {
+		n = 1
+	}
+	newPipes := make([]Pipe, n)
+	for i := range newPipes {
+		newPipes[i] = make(Pipe)
+	}
+	record(KindPartition, "partition", "", []Pipe{p}, newPipes)
+
+	go func() {
+		for input := range p {
+			newPipes[partitionIndex(keyFunc(input), n)] <- input
+		}
+
+		for _, output := range newPipes {
+			close(output)
+		}
+	}()
+
+	return newPipes
+}
+
+func partitionIndex(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// Overflow decides what happens to an item when branch buffer is full
+type Overflow int
+
+const (
+	// Block waits until branch consumer makes room in buffer
+	Block Overflow = iota
+	// DropNewest discards the item which does not fit into buffer
+	DropNewest
+	// DropOldest discards the oldest buffered item to make room for new one
+	DropOldest
+)
+
+// Branch describes a single output of Tee
+type Branch struct {
+	Buffer   int
+	Overflow Overflow
+}
+
+// Tee copies every item to each of given branches.
+// Each branch has its own buffer and overflow policy, so a slow consumer
+// of one branch does not stall the others until its own buffer is full,
+// and not at all when its policy drops items.
+// Negative buffer size is treated as 0, i.e. an unbuffered branch.
+// All returned pipes are closed when input pipe is closed.
+func (p Pipe) Tee(branches ...Branch) []Pipe {
+	newPipes := make([]Pipe, len(branches))
+	for i, b := range branches {
+		if b.Buffer < 0 {
+			b.Buffer = 0
+		}
+		newPipes[i] = make(Pipe, b.Buffer)
+	}
+	record(KindTee, "tee", "", []Pipe{p}, newPipes)
+
+	go func() {
+		for input := range p {
+			for i, b := range branches {
+				teeSend(newPipes[i], input, b.Overflow)
+			}
+		}
+
+		for _, output := range newPipes {
+			close(output)
+		}
+	}()
+
+	return newPipes
+}
+
+func teeSend(output Pipe, item interface{}, overflow Overflow) {
+	switch overflow {
+	case DropNewest:
+		select {
+		case output <- item:
+		default:
+		}
+	case DropOldest:
+		// without buffer there is nothing old to drop
+		if cap(output) == 0 {
+			teeSend(output, item, DropNewest)
+			return
+		}
+		for {
+			select {
+			case output <- item:
+				return
+			default:
+			}
+			select {
+			case <-output:
+			default:
+			}
+		}
+	default:
+		output <- item
+	}
+}
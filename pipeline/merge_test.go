@@ -0,0 +1,152 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func feed(items ...interface{}) Pipe {
+	p := make(Pipe)
+	go func() {
+		for _, v := range items {
+			p <- v
+		}
+		close(p)
+	}()
+	return p
+}
+
+func drain(p Pipe) []interface{} {
+	items := []interface{}{}
+	for v := range p {
+		items = append(items, v)
+	}
+	return items
+}
+
+func TestMerge(t *testing.T) {
+	Convey("Given three source pipes", t, func() {
+		a := feed("a1", "a2")
+		b := feed("b1")
+		c := feed()
+
+		Convey("When they are merged", func() {
+			items := drain(Merge(a, b, c))
+
+			Convey("Then all items are received and output is closed", func() {
+				So(len(items), ShouldEqual, 3)
+				So(items, ShouldContain, "a1")
+				So(items, ShouldContain, "a2")
+				So(items, ShouldContain, "b1")
+			})
+		})
+	})
+}
+
+func TestPartition(t *testing.T) {
+	Convey("Given iostat like lines for several disks", t, func() {
+		input := feed("sda 1", "sdb 1", "sda 2", "sdc 1", "sdb 2", "sda 3")
+		device := func(item interface{}) string {
+			return strings.Fields(item.(string))[0]
+		}
+
+		Convey("When input is partitioned by device", func() {
+			parts := input.Partition(device, 2)
+			results := make([][]interface{}, len(parts))
+
+			var wg sync.WaitGroup
+			wg.Add(len(parts))
+			for i, p := range parts {
+				go func(i int, p Pipe) {
+					results[i] = drain(p)
+					wg.Done()
+				}(i, p)
+			}
+			wg.Wait()
+
+			Convey("Then each device lands in a single partition in order", func() {
+				So(len(results[0])+len(results[1]), ShouldEqual, 6)
+				for i, items := range results {
+					values := map[string]string{}
+					for _, v := range items {
+						f := strings.Fields(v.(string))
+						So(partitionIndex(f[0], 2), ShouldEqual, i)
+						values[f[0]] += f[1]
+					}
+					for _, v := range values {
+						So(v, ShouldBeIn, []string{"1", "12", "123"})
+					}
+				}
+			})
+		})
+
+		Convey("When input is partitioned into zero pipes", func() {
+			parts := input.Partition(device, 0)
+
+			Convey("Then all items land in a single pipe", func() {
+				So(len(parts), ShouldEqual, 1)
+				So(len(drain(parts[0])), ShouldEqual, 6)
+			})
+		})
+	})
+}
+
+func TestTee(t *testing.T) {
+	Convey("Given a source pipe", t, func() {
+		input := feed(1, 2, 3, 4, 5)
+
+		Convey("When it is teed into a blocking and a dropping branch", func() {
+			branches := input.Tee(Branch{Buffer: 0, Overflow: Block}, Branch{Buffer: 2, Overflow: DropOldest})
+			blocking := drain(branches[0])
+			dropping := drain(branches[1])
+
+			Convey("Then the blocking branch gets all items", func() {
+				So(blocking, ShouldResemble, []interface{}{1, 2, 3, 4, 5})
+			})
+
+			Convey("Then the unread dropping branch keeps only the newest items", func() {
+				So(dropping, ShouldResemble, []interface{}{4, 5})
+			})
+		})
+
+		Convey("When it is teed into a branch dropping newest items", func() {
+			branches := input.Tee(Branch{Buffer: 0, Overflow: Block}, Branch{Buffer: 2, Overflow: DropNewest})
+			drain(branches[0])
+
+			Convey("Then the unread branch keeps only the first items", func() {
+				So(drain(branches[1]), ShouldResemble, []interface{}{1, 2})
+			})
+		})
+
+		Convey("When it is teed into a branch with negative buffer", func() {
+			branches := input.Tee(Branch{Buffer: -1, Overflow: Block})
+
+			Convey("Then the branch is unbuffered and gets all items", func() {
+				So(cap(branches[0]), ShouldEqual, 0)
+				So(drain(branches[0]), ShouldResemble, []interface{}{1, 2, 3, 4, 5})
+			})
+		})
+	})
+}
@@ -0,0 +1,280 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/intelsdi-x/snap-plugin-utilities/str"
+)
+
+// Line parsing stages consume strings (e.g. lines produced by source.Source)
+// and emit maps of fields. Items which are not strings are dropped.
+
+// RegexpMatch emits named groups of lines matching Regexp as map[string]string.
+// Lines which do not match are dropped.
+type RegexpMatch struct {
+	Regexp *regexp.Regexp
+}
+
+func (self RegexpMatch) Run(input, output Pipe) {
+	names := self.Regexp.SubexpNames()
+	for v := range input {
+		line, ok := v.(string)
+		if !ok {
+			continue
+		}
+		match := self.Regexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		fields := map[string]string{}
+		for i, name := range names {
+			if i > 0 && name != "" {
+				fields[name] = match[i]
+			}
+		}
+		output <- fields
+	}
+	close(output)
+}
+
+// SplitFields splits lines into columns and emits them as map[string]string
+// keyed by column headers.
+// Columns are separated by Separator or by whitespace if Separator is empty.
+// Header gives initial column names. Lines matching HeaderPattern replace
+// column names (and are not emitted), which handles headers repeated in
+// command output like in `iostat`. If neither Header nor HeaderPattern
+// is given, first line is used as header.
+// Blank lines are skipped. Columns without header are dropped, missing
+// columns are left out.
+type SplitFields struct {
+	Separator     string
+	Header        []string
+	HeaderPattern *regexp.Regexp
+}
+
+func (self SplitFields) Run(input, output Pipe) {
+	header := self.Header
+	for v := range input {
+		line, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		columns := self.split(line)
+		if (header == nil && self.HeaderPattern == nil) ||
+			(self.HeaderPattern != nil && self.HeaderPattern.MatchString(line)) {
+			header = columns
+			continue
+		}
+		fields := map[string]string{}
+		for i, column := range columns {
+			if i < len(header) {
+				fields[header[i]] = column
+			}
+		}
+		output <- fields
+	}
+	close(output)
+}
+
+func (self SplitFields) split(line string) []string {
+	if self.Separator == "" {
+		return strings.Fields(line)
+	}
+	columns := strings.Split(line, self.Separator)
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return columns
+}
+
+// KeyValue parses `key<Separator>value` pairs and emits them as map[string]string.
+// If PairSeparator is empty whole line is a single pair, otherwise line is split
+// into pairs first (e.g. `a=1,b=2` with PairSeparator ","). Separator defaults to "=".
+// Keys and values are trimmed, pairs without separator are dropped.
+// Lines which have no pairs are dropped.
+type KeyValue struct {
+	Separator     string
+	PairSeparator string
+}
+
+func (self KeyValue) Run(input, output Pipe) {
+	sep := self.Separator
+	if sep == "" {
+		sep = "="
+	}
+	for v := range input {
+		line, ok := v.(string)
+		if !ok {
+			continue
+		}
+		pairs := []string{line}
+		if self.PairSeparator != "" {
+			pairs = strings.Split(line, self.PairSeparator)
+		}
+		fields := map[string]string{}
+		for _, pair := range pairs {
+			kv := strings.SplitN(pair, sep, 2)
+			if len(kv) != 2 {
+				continue
+			}
+			fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		if len(fields) > 0 {
+			output <- fields
+		}
+	}
+	close(output)
+}
+
+// Section groups lines following a header line
+type Section struct {
+	Name  string
+	Lines []string
+}
+
+// Sections detects section headers with Header regular expression and emits
+// Section for each of them once the next header appears or input is closed.
+// Section name is the first submatch of Header or whole header line if
+// there is no submatch. Lines before the first header are dropped.
+type Sections struct {
+	Header *regexp.Regexp
+}
+
+func (self Sections) Run(input, output Pipe) {
+	var current *Section
+	for v := range input {
+		line, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if match := self.Header.FindStringSubmatch(line); match != nil {
+			if current != nil {
+				output <- *current
+			}
+			name := match[0]
+			if len(match) > 1 {
+				name = match[1]
+			}
+			current = &Section{Name: name, Lines: []string{}}
+			continue
+		}
+		if current != nil {
+			current.Lines = append(current.Lines, line)
+		}
+	}
+	if current != nil {
+		output <- *current
+	}
+	close(output)
+}
+
+// ParseNumbers converts numeric strings to int64, uint64 (when value does
+// not fit into int64) or float64.
+// It accepts single strings, map[string]string and map[string]interface{};
+// maps are emitted as map[string]interface{}. If Fields is not empty only
+// listed map keys are converted. Values which are not numbers, including
+// NaN and infinities, are left intact.
+type ParseNumbers struct {
+	Fields []string
+}
+
+func (self ParseNumbers) Run(input, output Pipe) {
+	for v := range input {
+		switch item := v.(type) {
+		case string:
+			output <- parseNumber(item)
+		case map[string]string:
+			fields := map[string]interface{}{}
+			for k, s := range item {
+				fields[k] = self.convert(k, s)
+			}
+			output <- fields
+		case map[string]interface{}:
+			fields := map[string]interface{}{}
+			for k, val := range item {
+				fields[k] = self.convert(k, val)
+			}
+			output <- fields
+		default:
+			output <- v
+		}
+	}
+	close(output)
+}
+
+func (self ParseNumbers) convert(key string, value interface{}) interface{} {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if len(self.Fields) > 0 && !str.Contains(self.Fields, key) {
+		return value
+	}
+	return parseNumber(s)
+}
+
+func parseNumber(s string) interface{} {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return u
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return f
+	}
+	return s
+}
+
+// CollectMap gathers map items into single map keyed by value of Key field,
+// e.g. device name, and emits it when input is closed.
+// Items without Key field are dropped.
+type CollectMap struct {
+	Key string
+}
+
+func (self CollectMap) Run(input, output Pipe) {
+	group := map[string]interface{}{}
+	for v := range input {
+		switch item := v.(type) {
+		case map[string]string:
+			if key, ok := item[self.Key]; ok {
+				group[key] = item
+			}
+		case map[string]interface{}:
+			if key, ok := item[self.Key]; ok {
+				group[fmt.Sprint(key)] = item
+			}
+		}
+	}
+
+	output <- group
+
+	close(output)
+}
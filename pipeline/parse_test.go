@@ -0,0 +1,177 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRegexpMatch(t *testing.T) {
+	Convey("Given lines and regexp with named groups", t, func() {
+		input := feed("eth0: rx 10 tx 20", "garbage", 42, "lo: rx 1 tx 2")
+		re := regexp.MustCompile(`^(?P<iface>\w+): rx (?P<rx>\d+) tx (?P<tx>\d+)$`)
+
+		Convey("Then matching lines are emitted as maps of named groups", func() {
+			items := drain(Pipeline(input, RegexpMatch{Regexp: re}))
+			So(items, ShouldResemble, []interface{}{
+				map[string]string{"iface": "eth0", "rx": "10", "tx": "20"},
+				map[string]string{"iface": "lo", "rx": "1", "tx": "2"},
+			})
+		})
+	})
+}
+
+func TestSplitFields(t *testing.T) {
+	Convey("Given iostat like output with repeated headers", t, func() {
+		input := feed(
+			"Device r/s w/s",
+			"sda 1.0 2.0",
+			"",
+			"Device r/s w/s",
+			"sdb 3.0 4.0 extra",
+		)
+
+		Convey("When header is taken from header pattern", func() {
+			items := drain(Pipeline(input, SplitFields{HeaderPattern: regexp.MustCompile(`^Device`)}))
+
+			Convey("Then each data line is mapped by column headers", func() {
+				So(items, ShouldResemble, []interface{}{
+					map[string]string{"Device": "sda", "r/s": "1.0", "w/s": "2.0"},
+					map[string]string{"Device": "sdb", "r/s": "3.0", "w/s": "4.0"},
+				})
+			})
+		})
+
+		Convey("When no header is configured", func() {
+			items := drain(Pipeline(input, SplitFields{}))
+
+			Convey("Then first line is used as header", func() {
+				So(len(items), ShouldEqual, 3)
+				So(items[0], ShouldResemble, map[string]string{"Device": "sda", "r/s": "1.0", "w/s": "2.0"})
+			})
+		})
+	})
+
+	Convey("Given comma separated lines and explicit header", t, func() {
+		input := feed("a, 1", "b, 2")
+		items := drain(Pipeline(input, SplitFields{Separator: ",", Header: []string{"name", "value"}}))
+
+		Convey("Then columns are trimmed and mapped", func() {
+			So(items, ShouldResemble, []interface{}{
+				map[string]string{"name": "a", "value": "1"},
+				map[string]string{"name": "b", "value": "2"},
+			})
+		})
+	})
+}
+
+func TestKeyValue(t *testing.T) {
+	Convey("Given key value lines", t, func() {
+		Convey("When line holds a single pair", func() {
+			items := drain(Pipeline(feed("MemTotal: 100 kB", "no pair here"), KeyValue{Separator: ":"}))
+
+			Convey("Then pair is emitted as map", func() {
+				So(items, ShouldResemble, []interface{}{map[string]string{"MemTotal": "100 kB"}})
+			})
+		})
+
+		Convey("When line holds many pairs", func() {
+			items := drain(Pipeline(feed("a=1, b=2,c"), KeyValue{PairSeparator: ","}))
+
+			Convey("Then all pairs are emitted in one map", func() {
+				So(items, ShouldResemble, []interface{}{map[string]string{"a": "1", "b": "2"}})
+			})
+		})
+	})
+}
+
+func TestSections(t *testing.T) {
+	Convey("Given sensors like output", t, func() {
+		input := feed("noise", "[coretemp-0]", "core0: 40", "core1: 41", "[acpitz-0]", "temp1: 30")
+		items := drain(Pipeline(input, Sections{Header: regexp.MustCompile(`^\[(.+)\]$`)}))
+
+		Convey("Then lines are grouped by sections", func() {
+			So(items, ShouldResemble, []interface{}{
+				Section{Name: "coretemp-0", Lines: []string{"core0: 40", "core1: 41"}},
+				Section{Name: "acpitz-0", Lines: []string{"temp1: 30"}},
+			})
+		})
+	})
+}
+
+func TestParseNumbers(t *testing.T) {
+	Convey("Given maps and strings with numbers", t, func() {
+		input := feed("12", "1.5", "x", map[string]string{"dev": "sda", "reads": "10", "util": "0.5"})
+
+		Convey("When all fields are converted", func() {
+			items := drain(Pipeline(input, ParseNumbers{}))
+
+			Convey("Then integers and floats are parsed", func() {
+				So(items, ShouldResemble, []interface{}{
+					int64(12), 1.5, "x",
+					map[string]interface{}{"dev": "sda", "reads": int64(10), "util": 0.5},
+				})
+			})
+		})
+
+		Convey("When only selected fields are converted", func() {
+			items := drain(Pipeline(input, ParseNumbers{Fields: []string{"util"}}))
+
+			Convey("Then other fields are left as strings", func() {
+				So(items[3], ShouldResemble, map[string]interface{}{"dev": "sda", "reads": "10", "util": 0.5})
+			})
+		})
+	})
+
+	Convey("Given strings with big and non-finite numbers", t, func() {
+		input := feed("18446744073709551615", "NaN", "+Inf", "-inf", "1e400")
+
+		Convey("When they are converted", func() {
+			items := drain(Pipeline(input, ParseNumbers{}))
+
+			Convey("Then values above int64 are parsed as uint64", func() {
+				So(items[0], ShouldEqual, uint64(18446744073709551615))
+			})
+
+			Convey("Then non-finite values are left as strings", func() {
+				So(items[1:], ShouldResemble, []interface{}{"NaN", "+Inf", "-inf", "1e400"})
+			})
+		})
+	})
+}
+
+func TestDeclarativeParsing(t *testing.T) {
+	Convey("Given command output parsed by chain of stages", t, func() {
+		input := feed("Device reads util", "sda 10 0.5", "sdb 20 0.25")
+		items := drain(Pipeline(input, SplitFields{}, ParseNumbers{}, CollectMap{Key: "Device"}))
+
+		Convey("Then a map per device is produced", func() {
+			So(items, ShouldResemble, []interface{}{
+				map[string]interface{}{
+					"sda": map[string]interface{}{"Device": "sda", "reads": int64(10), "util": 0.5},
+					"sdb": map[string]interface{}{"Device": "sdb", "reads": int64(20), "util": 0.25},
+				},
+			})
+		})
+	})
+}
@@ -21,6 +21,7 @@ package pipeline
 
 import (
 	"strings"
+	"sync"
 )
 
 // Pipeline processing interface
@@ -35,11 +36,13 @@ type Pipe chan interface{}
 // It returns last Pipe in Pipeline
 func (p Pipe) Next(proc Processor) Pipe {
 	outPipe := make(Pipe)
+	recordStage(proc, p, outPipe)
 	go proc.Run(p, outPipe)
 	return outPipe
 }
 
 func (p Pipe) Destination(pipes ...Pipe) {
+	record(KindDestination, "destination", "", []Pipe{p}, pipes)
 	go func() {
 		for input := range p {
 			for _, output := range pipes {
@@ -132,18 +135,23 @@ func (self Skip) Run(input, output Pipe) {
 }
 
 type LastValue struct {
-	last interface{}
+	mutex sync.Mutex
+	last  interface{}
 }
 
 func (self *LastValue) Run(input, output Pipe) {
 	for v := range input {
+		self.mutex.Lock()
 		self.last = v
+		self.mutex.Unlock()
 		output <- v
 	}
 	close(output)
 }
 
 func (self *LastValue) Last() interface{} {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
 	return self.last
 }
 
@@ -0,0 +1,153 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// PanicPolicy decides what happens to a stage after it panicked
+type PanicPolicy int
+
+const (
+	// FailPipeline closes stage output, so the rest of pipeline finishes,
+	// and drains stage input, so preceding stages finish too
+	FailPipeline PanicPolicy = iota
+	// SkipItem drops item which caused the panic and runs the same
+	// processor again on the following items, keeping its state
+	SkipItem
+	// RestartStage runs a fresh processor (see Guarded.New) on the following
+	// items, at most MaxRestarts times
+	RestartStage
+)
+
+// StageError is reported when stage panics
+type StageError struct {
+	Stage string
+	Panic interface{}
+	Stack []byte
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("Pipeline stage %s panicked: %v", e.Stage, e.Panic)
+}
+
+// Guarded wraps Processor and recovers its panics according to Policy.
+// Recovered panics are sent as *StageError to Errors (if not nil),
+// which has to be read by the caller.
+type Guarded struct {
+	Name      string
+	Processor Processor
+	Policy    PanicPolicy
+	Errors    chan error
+	// New creates fresh processor for RestartStage policy,
+	// if nil the same processor is run again
+	New func() Processor
+	// MaxRestarts limits restarts of the stage, 0 means no limit
+	MaxRestarts int
+}
+
+// Guard wraps processor with panic recovery under given stage name
+func Guard(name string, proc Processor, policy PanicPolicy, ech chan error) *Guarded {
+	return &Guarded{Name: name, Processor: proc, Policy: policy, Errors: ech}
+}
+
+// Run implements Processor, it runs wrapped processor until it finishes
+// or fails according to Policy. Stage which panics again without taking
+// any new item from input fails regardless of Policy.
+func (self *Guarded) Run(input, output Pipe) {
+	// consumed is incremented before item is offered to processor,
+	// so it is up to date when processor panics on that item
+	var consumed int64
+	innerIn := make(Pipe)
+	go func() {
+		for v := range input {
+			atomic.AddInt64(&consumed, 1)
+			innerIn <- v
+		}
+		close(innerIn)
+	}()
+
+	proc := self.Processor
+	restarts := 0
+	last := int64(-1)
+	for {
+		err := self.runOnce(proc, innerIn, output)
+		if err == nil {
+			return
+		}
+		if self.Errors != nil {
+			self.Errors <- err
+		}
+
+		n := atomic.LoadInt64(&consumed)
+		progress := n != last
+		last = n
+
+		switch {
+		case !progress:
+		case self.Policy == SkipItem:
+			continue
+		case self.Policy == RestartStage:
+			if self.MaxRestarts == 0 || restarts < self.MaxRestarts {
+				restarts++
+				if self.New != nil {
+					proc = self.New()
+				}
+				continue
+			}
+		}
+
+		safeClose(output)
+		for range innerIn {
+		}
+		return
+	}
+}
+
+func (self *Guarded) runOnce(proc Processor, input, output Pipe) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &StageError{Stage: self.Name, Panic: r, Stack: debug.Stack()}
+		}
+	}()
+	proc.Run(input, output)
+	return nil
+}
+
+// safeClose closes pipe which might have been already closed by panicking processor
+func safeClose(p Pipe) {
+	defer func() {
+		recover()
+	}()
+	close(p)
+}
+
+// GuardedPipeline works as Pipeline, but wraps each processor with panic
+// recovery using the same policy. Stages are named as in InstrumentedPipeline.
+func GuardedPipeline(input Pipe, policy PanicPolicy, ech chan error, processors ...Processor) Pipe {
+	guarded := make([]Processor, len(processors))
+	for i, proc := range processors {
+		guarded[i] = Guard(stageName(i, proc), proc, policy, ech)
+	}
+	return Pipeline(input, guarded...)
+}
@@ -0,0 +1,142 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// counter emits running count of items and panics on item "boom"
+type counter struct {
+	count int
+}
+
+func (self *counter) Run(input, output Pipe) {
+	for v := range input {
+		if v == "boom" {
+			panic("boom")
+		}
+		self.count++
+		output <- self.count
+	}
+	close(output)
+}
+
+// panicker panics before reading any input
+type panicker struct{}
+
+func (self panicker) Run(input, output Pipe) {
+	panic("broken stage")
+}
+
+func collectErrors(ech chan error) chan []error {
+	done := make(chan []error)
+	go func() {
+		errs := []error{}
+		for err := range ech {
+			errs = append(errs, err)
+		}
+		done <- errs
+	}()
+	return done
+}
+
+func TestGuardedPipeline(t *testing.T) {
+	Convey("Given stage which panics on non string item", t, func() {
+		ech := make(chan error)
+		errs := collectErrors(ech)
+		input := feed("a", 1, "ab", "b")
+
+		Convey("When panics are skipped", func() {
+			items := drain(GuardedPipeline(input, SkipItem, ech, StringContains{Str: "a"}))
+			close(ech)
+
+			Convey("Then offending item is dropped and processing continues", func() {
+				So(items, ShouldResemble, []interface{}{"a", "ab"})
+			})
+
+			Convey("Then error carries stage name and stack", func() {
+				e := <-errs
+				So(len(e), ShouldEqual, 1)
+				stageErr, ok := e[0].(*StageError)
+				So(ok, ShouldBeTrue)
+				So(stageErr.Stage, ShouldEqual, "0_stringcontains")
+				So(stageErr.Error(), ShouldContainSubstring, "Pipeline stage 0_stringcontains panicked")
+				So(string(stageErr.Stack), ShouldContainSubstring, "StringContains")
+			})
+		})
+
+		Convey("When panic fails the pipeline", func() {
+			items := drain(GuardedPipeline(input, FailPipeline, ech, StringContains{Str: "a"}, Collect{}))
+			close(ech)
+
+			Convey("Then pipeline finishes with items processed so far", func() {
+				So(items, ShouldResemble, []interface{}{[]interface{}{"a"}})
+				So(len(<-errs), ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given stateful stage which panics", t, func() {
+		ech := make(chan error, 10)
+
+		Convey("When panics are skipped", func() {
+			items := drain(Pipeline(feed("x", "boom", "y"), Guard("count", &counter{}, SkipItem, ech)))
+
+			Convey("Then state is kept", func() {
+				So(items, ShouldResemble, []interface{}{1, 2})
+			})
+		})
+
+		Convey("When stage is restarted with fresh processor", func() {
+			guard := Guard("count", &counter{}, RestartStage, ech)
+			guard.New = func() Processor { return &counter{} }
+			items := drain(Pipeline(feed("x", "boom", "y", "z"), guard))
+
+			Convey("Then state is reset", func() {
+				So(items, ShouldResemble, []interface{}{1, 1, 2})
+			})
+		})
+
+		Convey("When restarts are limited", func() {
+			guard := Guard("count", &counter{}, RestartStage, ech)
+			guard.MaxRestarts = 1
+			items := drain(Pipeline(feed("x", "boom", "y", "boom", "z"), guard))
+
+			Convey("Then stage fails after the limit", func() {
+				So(items, ShouldResemble, []interface{}{1, 2})
+				So(len(ech), ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given stage which panics without reading input", t, func() {
+		ech := make(chan error, 10)
+		items := drain(Pipeline(feed("x", "y"), Guard("broken", panicker{}, SkipItem, ech)))
+
+		Convey("Then stage fails instead of looping forever", func() {
+			So(items, ShouldBeEmpty)
+			// input forwarder may take the first item in between panics
+			So(len(ech), ShouldBeBetweenOrEqual, 2, 3)
+		})
+	})
+}
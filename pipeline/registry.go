@@ -0,0 +1,367 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Params holds stage parameters as decoded from JSON or YAML
+type Params map[string]interface{}
+
+// Factory creates Processor configured with given parameters
+type Factory func(params Params) (Processor, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = map[string]Factory{}
+)
+
+// Register makes processor factory available under given stage name.
+// Registering the same name twice replaces previous factory.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[name] = factory
+}
+
+// Registered returns sorted list of registered stage names
+func Registered() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProcessor creates processor registered under given stage name.
+// It returns error if stage is unknown or parameters are invalid.
+func NewProcessor(name string, params Params) (Processor, error) {
+	registryMutex.RLock()
+	factory, ok := registry[name]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown stage %q, available stages: %v", name, Registered())
+	}
+	if params == nil {
+		params = Params{}
+	}
+	return factory(params)
+}
+
+// Check returns error if params contain names other than allowed ones
+func (p Params) Check(allowed ...string) error {
+	for name := range p {
+		found := false
+		for _, a := range allowed {
+			if a == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("Unknown parameter %q, allowed parameters: %v", name, allowed)
+		}
+	}
+	return nil
+}
+
+// String returns string parameter or def if parameter is not set
+func (p Params) String(name string, def string) (string, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("Parameter %q must be a string, got %T", name, v)
+	}
+	return s, nil
+}
+
+// Int returns integer parameter or def if parameter is not set
+func (p Params) Int(name string, def int) (int, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		if n == float64(int(n)) {
+			return int(n), nil
+		}
+	}
+	return 0, fmt.Errorf("Parameter %q must be an integer, got %v", name, v)
+}
+
+// Float returns floating point parameter or def if parameter is not set
+func (p Params) Float(name string, def float64) (float64, error) {
+	v, ok := p[name]
+	if !ok {
+		return def, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case float64:
+		return n, nil
+	}
+	return 0, fmt.Errorf("Parameter %q must be a number, got %v", name, v)
+}
+
+// Duration returns duration parameter given as string (e.g. "1.5s") or def
+// if parameter is not set
+func (p Params) Duration(name string, def time.Duration) (time.Duration, error) {
+	s, err := p.String(name, "")
+	if err != nil || s == "" {
+		return def, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("Parameter %q is not a valid duration: %v", name, err)
+	}
+	return d, nil
+}
+
+// Strings returns list of strings parameter or nil if parameter is not set
+func (p Params) Strings(name string) ([]string, error) {
+	v, ok := p[name]
+	if !ok {
+		return nil, nil
+	}
+	switch list := v.(type) {
+	case []string:
+		return list, nil
+	case []interface{}:
+		strs := make([]string, len(list))
+		for i, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("Parameter %q must be a list of strings, item %d is %T", name, i, item)
+			}
+			strs[i] = s
+		}
+		return strs, nil
+	}
+	return nil, fmt.Errorf("Parameter %q must be a list of strings, got %T", name, v)
+}
+
+// Regexp returns compiled regular expression parameter or nil if parameter is not set
+func (p Params) Regexp(name string) (*regexp.Regexp, error) {
+	s, err := p.String(name, "")
+	if err != nil || s == "" {
+		return nil, err
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("Parameter %q is not a valid regular expression: %v", name, err)
+	}
+	return re, nil
+}
+
+// requiredRegexp returns compiled regular expression parameter which must be set
+func (p Params) requiredRegexp(name string) (*regexp.Regexp, error) {
+	re, err := p.Regexp(name)
+	if err == nil && re == nil {
+		err = fmt.Errorf("Parameter %q is required", name)
+	}
+	return re, err
+}
+
+func init() {
+	Register("string_contains", func(p Params) (Processor, error) {
+		if err := p.Check("str"); err != nil {
+			return nil, err
+		}
+		s, err := p.String("str", "")
+		if err != nil {
+			return nil, err
+		}
+		return StringContains{Str: s}, nil
+	})
+	Register("skip", func(p Params) (Processor, error) {
+		if err := p.Check("count"); err != nil {
+			return nil, err
+		}
+		count, err := p.Int("count", 0)
+		if err != nil {
+			return nil, err
+		}
+		return Skip{Count: count}, nil
+	})
+	Register("collect", func(p Params) (Processor, error) {
+		return Collect{}, p.Check()
+	})
+	Register("nonblocking", func(p Params) (Processor, error) {
+		return Nonblocking{}, p.Check()
+	})
+	Register("regexp", func(p Params) (Processor, error) {
+		if err := p.Check("pattern"); err != nil {
+			return nil, err
+		}
+		re, err := p.requiredRegexp("pattern")
+		if err != nil {
+			return nil, err
+		}
+		return RegexpMatch{Regexp: re}, nil
+	})
+	Register("split_fields", func(p Params) (Processor, error) {
+		if err := p.Check("separator", "header", "header_pattern"); err != nil {
+			return nil, err
+		}
+		sep, err := p.String("separator", "")
+		if err != nil {
+			return nil, err
+		}
+		header, err := p.Strings("header")
+		if err != nil {
+			return nil, err
+		}
+		re, err := p.Regexp("header_pattern")
+		if err != nil {
+			return nil, err
+		}
+		return SplitFields{Separator: sep, Header: header, HeaderPattern: re}, nil
+	})
+	Register("key_value", func(p Params) (Processor, error) {
+		if err := p.Check("separator", "pair_separator"); err != nil {
+			return nil, err
+		}
+		sep, err := p.String("separator", "")
+		if err != nil {
+			return nil, err
+		}
+		pairSep, err := p.String("pair_separator", "")
+		if err != nil {
+			return nil, err
+		}
+		return KeyValue{Separator: sep, PairSeparator: pairSep}, nil
+	})
+	Register("sections", func(p Params) (Processor, error) {
+		if err := p.Check("header"); err != nil {
+			return nil, err
+		}
+		re, err := p.requiredRegexp("header")
+		if err != nil {
+			return nil, err
+		}
+		return Sections{Header: re}, nil
+	})
+	Register("parse_numbers", func(p Params) (Processor, error) {
+		if err := p.Check("fields"); err != nil {
+			return nil, err
+		}
+		fields, err := p.Strings("fields")
+		if err != nil {
+			return nil, err
+		}
+		return ParseNumbers{Fields: fields}, nil
+	})
+	Register("collect_map", func(p Params) (Processor, error) {
+		if err := p.Check("key"); err != nil {
+			return nil, err
+		}
+		key, err := p.String("key", "")
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, fmt.Errorf("Parameter %q is required", "key")
+		}
+		return CollectMap{Key: key}, nil
+	})
+	Register("rate_limit", func(p Params) (Processor, error) {
+		if err := p.Check("per_second", "burst"); err != nil {
+			return nil, err
+		}
+		perSecond, err := p.Float("per_second", 0)
+		if err != nil {
+			return nil, err
+		}
+		if perSecond <= 0 {
+			return nil, fmt.Errorf("Parameter %q must be greater than 0", "per_second")
+		}
+		burst, err := p.Int("burst", 1)
+		if err != nil {
+			return nil, err
+		}
+		return RateLimit{PerSecond: perSecond, Burst: burst}, nil
+	})
+	Register("debounce", func(p Params) (Processor, error) {
+		if err := p.Check("delay"); err != nil {
+			return nil, err
+		}
+		delay, err := p.Duration("delay", 0)
+		if err != nil {
+			return nil, err
+		}
+		return Debounce{Delay: delay}, nil
+	})
+	Register("dedup", func(p Params) (Processor, error) {
+		if err := p.Check("ttl"); err != nil {
+			return nil, err
+		}
+		ttl, err := p.Duration("ttl", 0)
+		if err != nil {
+			return nil, err
+		}
+		return Dedup{KeyFunc: func(item interface{}) string { return fmt.Sprint(item) }, TTL: ttl}, nil
+	})
+	Register("sample", func(p Params) (Processor, error) {
+		if err := p.Check("every"); err != nil {
+			return nil, err
+		}
+		every, err := p.Int("every", 1)
+		if err != nil {
+			return nil, err
+		}
+		return Sample{Every: every}, nil
+	})
+	Register("batch", func(p Params) (Processor, error) {
+		if err := p.Check("max_size", "max_age"); err != nil {
+			return nil, err
+		}
+		size, err := p.Int("max_size", 0)
+		if err != nil {
+			return nil, err
+		}
+		age, err := p.Duration("max_age", 0)
+		if err != nil {
+			return nil, err
+		}
+		if size <= 0 && age <= 0 {
+			return nil, fmt.Errorf("Parameter %q or %q is required", "max_size", "max_age")
+		}
+		return Batch{MaxSize: size, MaxAge: age}, nil
+	})
+}
@@ -0,0 +1,109 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// recordedItem is a line of file written by Recorder
+type recordedItem struct {
+	Time time.Time   `json:"time"`
+	Item interface{} `json:"item"`
+}
+
+// Recorder passes items through and writes each of them with its arrival
+// time as a JSON line to Writer, so the input can be replayed later with Replay.
+// After first write error recording stops, the error is sent to Errors if set.
+// Clock defaults to SystemClock.
+type Recorder struct {
+	Writer io.Writer
+	Errors chan error
+	Clock  Clock
+}
+
+func (self Recorder) Run(input, output Pipe) {
+	clock := clockOrSystem(self.Clock)
+	encoder := json.NewEncoder(self.Writer)
+	failed := false
+	for v := range input {
+		if !failed {
+			if err := encoder.Encode(recordedItem{Time: clock.Now(), Item: v}); err != nil {
+				failed = true
+				if self.Errors != nil {
+					self.Errors <- fmt.Errorf("Cannot record pipeline input: %v", err)
+				}
+			}
+		}
+		output <- v
+	}
+	close(output)
+}
+
+// Replay re-feeds input recorded by Recorder from file at Path. It
+// implements source.Sourcer, so it can replace command source of a pipeline.
+// Items are decoded from JSON, so strings (e.g. command output lines) are
+// replayed as they were. With Speed 0 items are sent as fast as they are
+// consumed, otherwise original gaps between items are kept, divided by Speed.
+// Clock defaults to SystemClock.
+type Replay struct {
+	Path  string
+	Speed float64
+	Clock Clock
+}
+
+// Generate sends recorded items to out and closes it at the end of file.
+// Errors (including malformed lines, which stop the replay) are sent to ech.
+func (self Replay) Generate(out chan interface{}, ech chan error) {
+	defer close(out)
+	clock := clockOrSystem(self.Clock)
+	f, err := os.Open(self.Path)
+	if err != nil {
+		ech <- err
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	var last time.Time
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec recordedItem
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			ech <- fmt.Errorf("Cannot replay %s line %d: %v", self.Path, line, err)
+			return
+		}
+		if self.Speed > 0 && !last.IsZero() && rec.Time.After(last) {
+			<-clock.After(time.Duration(float64(rec.Time.Sub(last)) / self.Speed))
+		}
+		last = rec.Time
+		out <- rec.Item
+	}
+	if err := scanner.Err(); err != nil {
+		ech <- fmt.Errorf("Cannot replay %s: %v", self.Path, err)
+	}
+}
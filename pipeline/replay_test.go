@@ -0,0 +1,89 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReplay(t *testing.T) {
+	Convey("Given recorded pipeline input", t, func() {
+		dir, err := ioutil.TempDir("", "pipeline")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "input.jsonl")
+
+		clock := newFakeClock()
+		var buf bytes.Buffer
+		s := newStepper(clock, Recorder{Writer: &buf, Clock: clock})
+		s.send("sda 1 2")
+		clock.Advance(2 * time.Second)
+		s.send("sdb 3 4")
+		So(s.close(), ShouldResemble, []interface{}{"sda 1 2", "sdb 3 4"})
+		So(ioutil.WriteFile(path, buf.Bytes(), 0644), ShouldBeNil)
+
+		Convey("When it is replayed as fast as possible", func() {
+			out := make(chan interface{})
+			ech := make(chan error, 1)
+			go Replay{Path: path}.Generate(out, ech)
+
+			Convey("Then the same items are fed", func() {
+				So(drain(out), ShouldResemble, []interface{}{"sda 1 2", "sdb 3 4"})
+				So(ech, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When it is replayed at double speed", func() {
+			replayClock := newFakeClock()
+			out := make(Pipe)
+			go Replay{Path: path, Speed: 2, Clock: replayClock}.Generate(out, nil)
+
+			Convey("Then gaps between items are kept", func() {
+				So(<-out, ShouldEqual, "sda 1 2")
+				replayClock.WaitForTimers(1)
+				replayClock.Advance(999 * time.Millisecond)
+				replayClock.WaitForTimers(1)
+				replayClock.Advance(time.Millisecond)
+				So(drain(out), ShouldResemble, []interface{}{"sdb 3 4"})
+			})
+		})
+
+		Convey("When recording is malformed", func() {
+			So(ioutil.WriteFile(path, append(buf.Bytes(), "garbage\n"...), 0644), ShouldBeNil)
+			out := make(chan interface{})
+			ech := make(chan error, 1)
+			go Replay{Path: path}.Generate(out, ech)
+
+			Convey("Then replay stops with error", func() {
+				So(len(drain(out)), ShouldEqual, 2)
+				err := <-ech
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "Cannot replay "+path+" line 3:")
+			})
+		})
+	})
+}
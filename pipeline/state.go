@@ -0,0 +1,281 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateStore keeps serialized state of stages under string keys.
+// Load returns nil data without error when no state was saved under key.
+type StateStore interface {
+	Save(key string, data []byte) error
+	Load(key string) ([]byte, error)
+}
+
+// Stateful is implemented by stages which can save their state (e.g. open
+// windows or aggregates) and restore it after plugin restart
+type Stateful interface {
+	Checkpoint() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// FileStore is StateStore keeping each key in a separate file in Dir.
+// Files are replaced atomically, so crash during Save leaves previous state intact.
+type FileStore struct {
+	Dir string
+}
+
+// Save writes state to file, creating Dir if needed
+func (self FileStore) Save(key string, data []byte) error {
+	path, err := self.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(self.Dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(self.Dir, "."+key)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Load reads state from file, missing file means no state
+func (self FileStore) Load(key string) ([]byte, error) {
+	path, err := self.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (self FileStore) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("Invalid state key %q", key)
+	}
+	return filepath.Join(self.Dir, key+".state"), nil
+}
+
+// DefaultCheckpointInterval is used by Checkpointer when its Interval
+// is not positive
+const DefaultCheckpointInterval = time.Minute
+
+// Checkpointer saves state of registered stages to Store periodically
+type Checkpointer struct {
+	Store StateStore
+	// Interval between checkpoints, DefaultCheckpointInterval if not positive
+	Interval time.Duration
+	Clock    Clock
+
+	mutex  sync.Mutex
+	stages map[string]Stateful
+}
+
+// NewCheckpointer creates checkpointer saving state to store every interval
+func NewCheckpointer(store StateStore, interval time.Duration) *Checkpointer {
+	return &Checkpointer{Store: store, Interval: interval}
+}
+
+// Add registers stage under key and restores its state saved previously, if any.
+// It should be called before stage starts processing items.
+func (self *Checkpointer) Add(key string, stage Stateful) error {
+	data, err := self.Store.Load(key)
+	if err != nil {
+		return fmt.Errorf("Cannot load state of %q: %v", key, err)
+	}
+	if data != nil {
+		if err := stage.Restore(data); err != nil {
+			return fmt.Errorf("Cannot restore state of %q: %v", key, err)
+		}
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	if self.stages == nil {
+		self.stages = map[string]Stateful{}
+	}
+	self.stages[key] = stage
+	return nil
+}
+
+// Checkpoint saves state of all registered stages, it returns first error
+// encountered but still tries to save remaining stages
+func (self *Checkpointer) Checkpoint() error {
+	type entry struct {
+		key   string
+		stage Stateful
+	}
+	self.mutex.Lock()
+	entries := make([]entry, 0, len(self.stages))
+	for key, stage := range self.stages {
+		entries = append(entries, entry{key, stage})
+	}
+	self.mutex.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	var first error
+	for _, e := range entries {
+		data, err := e.stage.Checkpoint()
+		if err == nil {
+			err = self.Store.Save(e.key, data)
+		}
+		if err != nil && first == nil {
+			first = fmt.Errorf("Cannot checkpoint state of %q: %v", e.key, err)
+		}
+	}
+	return first
+}
+
+// Run checkpoints state every Interval until stop is closed, then makes
+// final checkpoint and returns. Errors are sent to ech if it is not nil.
+func (self *Checkpointer) Run(stop <-chan struct{}, ech chan error) {
+	clock := clockOrSystem(self.Clock)
+	interval := self.Interval
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+	report := func(err error) {
+		if err != nil && ech != nil {
+			ech <- err
+		}
+	}
+	for {
+		select {
+		case <-clock.After(interval):
+			report(self.Checkpoint())
+		case <-stop:
+			report(self.Checkpoint())
+			return
+		}
+	}
+}
+
+// Checkpoint implements Stateful, values are encoded as JSON,
+// so after Restore they hold JSON types (string, float64, map[string]interface{}, ...)
+func (self *Latest) Checkpoint() ([]byte, error) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+	return json.Marshal(self.values)
+}
+
+// Restore implements Stateful, it replaces all stored values
+func (self *Latest) Restore(data []byte) error {
+	values := map[string]Entry{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	self.mutex.Lock()
+	self.values = values
+	self.mutex.Unlock()
+	return nil
+}
+
+// BatchState holds pending batch of Batch stage, so it can be checkpointed.
+// It implements Stateful, items are encoded as JSON like in Latest.
+type BatchState struct {
+	mutex   sync.Mutex
+	pending []interface{}
+}
+
+// Checkpoint implements Stateful
+func (self *BatchState) Checkpoint() ([]byte, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return json.Marshal(self.pending)
+}
+
+// Restore implements Stateful, restored items are emitted in the first batch
+func (self *BatchState) Restore(data []byte) error {
+	pending := []interface{}{}
+	if err := json.Unmarshal(data, &pending); err != nil {
+		return err
+	}
+	self.set(pending)
+	return nil
+}
+
+func (self *BatchState) get() []interface{} {
+	if self == nil {
+		return nil
+	}
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return self.pending
+}
+
+func (self *BatchState) set(pending []interface{}) {
+	if self == nil {
+		return
+	}
+	self.mutex.Lock()
+	self.pending = pending
+	self.mutex.Unlock()
+}
+
+// DedupState holds keys seen by Dedup stage with time they were seen,
+// so it can be checkpointed. It implements Stateful.
+type DedupState struct {
+	mutex sync.Mutex
+	seen  map[string]time.Time
+}
+
+// Checkpoint implements Stateful
+func (self *DedupState) Checkpoint() ([]byte, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+	return json.Marshal(self.seen)
+}
+
+// Restore implements Stateful, it replaces all seen keys
+func (self *DedupState) Restore(data []byte) error {
+	seen := map[string]time.Time{}
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return err
+	}
+	self.mutex.Lock()
+	self.seen = seen
+	self.mutex.Unlock()
+	return nil
+}
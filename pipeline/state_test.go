@@ -0,0 +1,254 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// memoryStore is StateStore counting saves
+type memoryStore struct {
+	mutex sync.Mutex
+	data  map[string][]byte
+	saves int
+}
+
+func (s *memoryStore) Save(key string, data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.data == nil {
+		s.data = map[string][]byte{}
+	}
+	s.data[key] = data
+	s.saves++
+	return nil
+}
+
+func (s *memoryStore) Load(key string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.data[key], nil
+}
+
+func (s *memoryStore) Saves() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.saves
+}
+
+type brokenState struct{}
+
+func (brokenState) Checkpoint() ([]byte, error) { return nil, errors.New("broken") }
+func (brokenState) Restore(data []byte) error   { return errors.New("broken") }
+
+func TestFileStore(t *testing.T) {
+	Convey("Given file store in temporary directory", t, func() {
+		dir, err := ioutil.TempDir("", "pipeline")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		store := FileStore{Dir: filepath.Join(dir, "state")}
+
+		Convey("Then missing state is loaded as nil", func() {
+			data, err := store.Load("latest")
+			So(err, ShouldBeNil)
+			So(data, ShouldBeNil)
+		})
+
+		Convey("When state is saved twice", func() {
+			So(store.Save("latest", []byte("one")), ShouldBeNil)
+			So(store.Save("latest", []byte("two")), ShouldBeNil)
+
+			Convey("Then the last state is loaded", func() {
+				data, err := store.Load("latest")
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "two")
+			})
+
+			Convey("Then no temporary files are left", func() {
+				files, _ := ioutil.ReadDir(store.Dir)
+				So(len(files), ShouldEqual, 1)
+				So(files[0].Name(), ShouldEqual, "latest.state")
+			})
+		})
+
+		Convey("Then keys escaping directory are rejected", func() {
+			So(store.Save("../latest", nil), ShouldNotBeNil)
+			_, err := store.Load("")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestCheckpointer(t *testing.T) {
+	Convey("Given latest value cache with saved state", t, func() {
+		clock := newFakeClock()
+		store := &memoryStore{}
+		first := &Latest{Clock: clock}
+		Pipeline(feed("a"), first).Destination()
+		data, err := first.Checkpoint()
+		So(err, ShouldBeNil)
+		store.Save("latest", data)
+
+		Convey("When cache is added to checkpointer", func() {
+			cp := NewCheckpointer(store, time.Minute)
+			cp.Clock = clock
+			restored := &Latest{Clock: clock}
+			So(cp.Add("latest", restored), ShouldBeNil)
+
+			Convey("Then its state is restored", func() {
+				So(restored.Snapshot(), ShouldResemble, first.Snapshot())
+			})
+
+			Convey("Then state is saved periodically and when stopped", func() {
+				stop := make(chan struct{})
+				done := make(chan struct{})
+				go func() {
+					cp.Run(stop, nil)
+					close(done)
+				}()
+				clock.WaitForTimers(1)
+				clock.Advance(time.Minute)
+				clock.WaitForTimers(1)
+				So(store.Saves(), ShouldEqual, 2)
+				close(stop)
+				<-done
+				So(store.Saves(), ShouldEqual, 3)
+			})
+
+			Convey("Then zero interval falls back to default", func() {
+				cp.Interval = 0
+				stop := make(chan struct{})
+				done := make(chan struct{})
+				go func() {
+					cp.Run(stop, nil)
+					close(done)
+				}()
+				clock.WaitForTimers(1)
+				clock.Advance(DefaultCheckpointInterval - time.Second)
+				So(store.Saves(), ShouldEqual, 1)
+				clock.Advance(time.Second)
+				clock.WaitForTimers(1)
+				So(store.Saves(), ShouldEqual, 2)
+				close(stop)
+				<-done
+			})
+		})
+
+		Convey("Then stage failing to restore is reported", func() {
+			store.Save("broken", []byte("{}"))
+			err := NewCheckpointer(store, time.Minute).Add("broken", brokenState{})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, `Cannot restore state of "broken": broken`)
+		})
+
+		Convey("Then checkpoint errors are reported and other stages are saved", func() {
+			cp := NewCheckpointer(&memoryStore{}, time.Minute)
+			So(cp.Add("a_broken", brokenState{}), ShouldBeNil)
+			So(cp.Add("latest", first), ShouldBeNil)
+			err := cp.Checkpoint()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, `Cannot checkpoint state of "a_broken": broken`)
+			So(cp.Store.(*memoryStore).Saves(), ShouldEqual, 1)
+		})
+
+		Convey("Then stages may be added while checkpointing", func() {
+			cp := NewCheckpointer(&memoryStore{}, time.Minute)
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				for i := 0; i < 100; i++ {
+					cp.Add(fmt.Sprintf("latest%d", i), first)
+				}
+				wg.Done()
+			}()
+			go func() {
+				for i := 0; i < 100; i++ {
+					cp.Checkpoint()
+				}
+				wg.Done()
+			}()
+			wg.Wait()
+			So(cp.Checkpoint(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given batch stage with state", t, func() {
+		state := &BatchState{}
+		input := make(Pipe)
+		output := Pipeline(input, Batch{MaxSize: 3, State: state})
+		input <- "a"
+		input <- "b"
+		for i := 0; i < 1000 && len(state.get()) < 2; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		Convey("When its state is restored into new stage", func() {
+			data, err := state.Checkpoint()
+			So(err, ShouldBeNil)
+			restored := &BatchState{}
+			So(restored.Restore(data), ShouldBeNil)
+
+			Convey("Then pending items are emitted in its first batch", func() {
+				So(drain(Pipeline(feed("c", "d"), Batch{MaxSize: 3, State: restored})), ShouldResemble, []interface{}{
+					[]interface{}{"a", "b", "c"},
+					[]interface{}{"d"},
+				})
+			})
+		})
+
+		Convey("When batch is emitted", func() {
+			input <- "c"
+			So(<-output, ShouldResemble, []interface{}{"a", "b", "c"})
+
+			Convey("Then nothing is pending", func() {
+				data, err := state.Checkpoint()
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "null")
+				close(input)
+			})
+		})
+	})
+
+	Convey("Given dedup stage with state", t, func() {
+		state := &DedupState{}
+		key := func(item interface{}) string { return item.(string) }
+		So(drain(Pipeline(feed("a", "b"), Dedup{KeyFunc: key, State: state})), ShouldResemble, []interface{}{"a", "b"})
+
+		Convey("When its state is restored into new stage", func() {
+			data, err := state.Checkpoint()
+			So(err, ShouldBeNil)
+			restored := &DedupState{}
+			So(restored.Restore(data), ShouldBeNil)
+
+			Convey("Then seen keys are remembered", func() {
+				So(drain(Pipeline(feed("a", "c", "b"), Dedup{KeyFunc: key, State: restored})), ShouldResemble, []interface{}{"c"})
+			})
+		})
+	})
+}
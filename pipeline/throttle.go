@@ -0,0 +1,160 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"time"
+)
+
+// RateLimit passes at most PerSecond items per second on average and drops
+// the rest. Up to Burst items may pass at once after a quiet period
+// (token bucket), Burst lower than 1 is treated as 1.
+// Clock defaults to SystemClock.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+	Clock     Clock
+}
+
+func (self RateLimit) Run(input, output Pipe) {
+	clock := clockOrSystem(self.Clock)
+	burst := float64(self.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	tokens := burst
+	var last time.Time
+	for v := range input {
+		now := clock.Now()
+		if !last.IsZero() {
+			tokens += now.Sub(last).Seconds() * self.PerSecond
+			if tokens > burst {
+				tokens = burst
+			}
+		}
+		last = now
+		if tokens >= 1 {
+			tokens--
+			output <- v
+		}
+	}
+	close(output)
+}
+
+// Debounce emits an item only when no other item arrived during Delay
+// after it, so from a burst of items only the last one is emitted.
+// Pending item is emitted when input is closed.
+// Clock defaults to SystemClock.
+type Debounce struct {
+	Delay time.Duration
+	Clock Clock
+}
+
+func (self Debounce) Run(input, output Pipe) {
+	clock := clockOrSystem(self.Clock)
+	var pending interface{}
+	var timer <-chan time.Time
+	for {
+		select {
+		case v, ok := <-input:
+			if !ok {
+				if timer != nil {
+					output <- pending
+				}
+				close(output)
+				return
+			}
+			pending = v
+			timer = clock.After(self.Delay)
+		case <-timer:
+			output <- pending
+			pending = nil
+			timer = nil
+		}
+	}
+}
+
+// Dedup drops items whose key (as returned by KeyFunc) was already passed
+// within TTL. TTL equal to 0 means keys are remembered forever.
+// If State is set, seen keys are kept in it, so they can be checkpointed.
+// Clock defaults to SystemClock.
+type Dedup struct {
+	KeyFunc KeyFunc
+	TTL     time.Duration
+	State   *DedupState
+	Clock   Clock
+}
+
+func (self Dedup) Run(input, output Pipe) {
+	clock := clockOrSystem(self.Clock)
+	state := self.State
+	if state == nil {
+		state = &DedupState{}
+	}
+	var lastSweep time.Time
+	for v := range input {
+		now := clock.Now()
+		if lastSweep.IsZero() {
+			lastSweep = now
+		}
+		state.mutex.Lock()
+		if state.seen == nil {
+			state.seen = map[string]time.Time{}
+		}
+		if self.TTL > 0 && now.Sub(lastSweep) >= self.TTL {
+			// forget expired keys, so memory does not grow with key history
+			for key, t := range state.seen {
+				if now.Sub(t) >= self.TTL {
+					delete(state.seen, key)
+				}
+			}
+			lastSweep = now
+		}
+
+		key := self.KeyFunc(v)
+		t, found := state.seen[key]
+		duplicate := found && (self.TTL == 0 || now.Sub(t) < self.TTL)
+		if !duplicate {
+			state.seen[key] = now
+		}
+		state.mutex.Unlock()
+		if !duplicate {
+			output <- v
+		}
+	}
+	close(output)
+}
+
+// Sample passes first of every Every items (1st, Every+1st, ...).
+// Every lower than 2 passes all items.
+type Sample struct {
+	Every int
+}
+
+func (self Sample) Run(input, output Pipe) {
+	i := 0
+	for v := range input {
+		if self.Every < 2 || i%self.Every == 0 {
+			output <- v
+		}
+		i++
+	}
+	close(output)
+}
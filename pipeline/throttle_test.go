@@ -0,0 +1,219 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeClock is Clock which moves only when advanced by test
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	reads  int
+	timers []fakeTimer
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reads++
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves clock forward and fires expired timers
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			active = append(active, t)
+		} else {
+			t.ch <- c.now
+		}
+	}
+	c.timers = active
+}
+
+// waitFor blocks until condition on clock state is met
+func (c *fakeClock) waitFor(cond func() bool) {
+	for {
+		c.mutex.Lock()
+		done := cond()
+		c.mutex.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// WaitForTimers blocks until n timers are waiting to fire
+func (c *fakeClock) WaitForTimers(n int) {
+	c.waitFor(func() bool { return len(c.timers) >= n })
+}
+
+// stepper feeds stage which reads clock once per item
+type stepper struct {
+	clock  *fakeClock
+	input  Pipe
+	output Pipe
+}
+
+func newStepper(clock *fakeClock, proc Processor) *stepper {
+	s := &stepper{clock: clock, input: make(Pipe), output: make(Pipe, 100)}
+	go proc.Run(s.input, s.output)
+	return s
+}
+
+// send delivers items one by one and waits until stage reads the time
+// for each of them, so the clock can be safely advanced afterwards
+func (s *stepper) send(items ...interface{}) {
+	for _, v := range items {
+		s.clock.mutex.Lock()
+		reads := s.clock.reads
+		s.clock.mutex.Unlock()
+		s.input <- v
+		s.clock.waitFor(func() bool { return s.clock.reads > reads })
+	}
+}
+
+func (s *stepper) close() []interface{} {
+	close(s.input)
+	return drain(s.output)
+}
+
+func TestRateLimit(t *testing.T) {
+	Convey("Given rate limit of 2 items per second with burst of 3", t, func() {
+		clock := newFakeClock()
+		s := newStepper(clock, RateLimit{PerSecond: 2, Burst: 3, Clock: clock})
+
+		Convey("When items flow in bursts", func() {
+			s.send(1, 2, 3, 4, 5)
+			clock.Advance(500 * time.Millisecond)
+			s.send(6, 7)
+			clock.Advance(10 * time.Second)
+			s.send(8, 9, 10, 11)
+
+			Convey("Then only items within the rate pass", func() {
+				So(s.close(), ShouldResemble, []interface{}{1, 2, 3, 6, 8, 9, 10})
+			})
+		})
+	})
+}
+
+func TestDebounce(t *testing.T) {
+	Convey("Given debounce of one second", t, func() {
+		clock := newFakeClock()
+		input := make(Pipe)
+		output := make(Pipe, 100)
+		go Debounce{Delay: time.Second, Clock: clock}.Run(input, output)
+
+		Convey("When items come in bursts separated by quiet periods", func() {
+			input <- "a1"
+			clock.WaitForTimers(1)
+			clock.Advance(500 * time.Millisecond)
+			input <- "a2"
+			clock.WaitForTimers(2)
+			clock.Advance(time.Second)
+			So(<-output, ShouldEqual, "a2")
+
+			input <- "b1"
+			clock.WaitForTimers(1)
+			input <- "b2"
+			close(input)
+
+			Convey("Then only last item of each burst is emitted", func() {
+				So(drain(output), ShouldResemble, []interface{}{"b2"})
+			})
+		})
+	})
+}
+
+func TestDedup(t *testing.T) {
+	Convey("Given dedup with ttl of one minute", t, func() {
+		clock := newFakeClock()
+		key := func(item interface{}) string { return item.(string)[:1] }
+		s := newStepper(clock, Dedup{KeyFunc: key, TTL: time.Minute, Clock: clock})
+
+		Convey("When the same keys repeat", func() {
+			s.send("a1", "b1", "a2")
+			clock.Advance(30 * time.Second)
+			s.send("a3", "c1")
+			clock.Advance(30 * time.Second)
+			s.send("a4", "b2", "c2")
+
+			Convey("Then keys pass again only after ttl", func() {
+				So(s.close(), ShouldResemble, []interface{}{"a1", "b1", "c1", "a4", "b2"})
+			})
+		})
+	})
+
+	Convey("Given dedup without ttl", t, func() {
+		clock := newFakeClock()
+		s := newStepper(clock, Dedup{KeyFunc: func(item interface{}) string { return item.(string) }, Clock: clock})
+		s.send("a", "b", "a", "b", "c")
+
+		Convey("Then each key passes once", func() {
+			So(s.close(), ShouldResemble, []interface{}{"a", "b", "c"})
+		})
+	})
+}
+
+func TestSample(t *testing.T) {
+	Convey("Given sampling every third item", t, func() {
+		items := drain(Pipeline(feed(1, 2, 3, 4, 5, 6, 7), Sample{Every: 3}))
+
+		Convey("Then first of every three items passes", func() {
+			So(items, ShouldResemble, []interface{}{1, 4, 7})
+		})
+	})
+
+	Convey("Given sampling every item", t, func() {
+		items := drain(Pipeline(feed(1, 2), Sample{}))
+
+		Convey("Then all items pass", func() {
+			So(items, ShouldResemble, []interface{}{1, 2})
+		})
+	})
+}
@@ -0,0 +1,275 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Kinds of topology nodes
+const (
+	KindSource      = "source"
+	KindStage       = "stage"
+	KindDestination = "destination"
+	KindMerge       = "merge"
+	KindPartition   = "partition"
+	KindTee         = "tee"
+	KindSink        = "sink"
+)
+
+// Node is a vertex of pipeline topology: a stage, a fan-in/fan-out point,
+// a source pipe created outside of the package or a sink marked with Topology.Sink
+type Node struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	Type string `json:"type,omitempty"`
+}
+
+// Edge is a pipe connecting two nodes, To is -1 for pipe without consumer
+type Edge struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Topology records nodes and pipes of pipelines while they are built.
+// Recording is enabled with Record.
+type Topology struct {
+	mutex sync.Mutex
+	nodes []Node
+	pipes []*pipeEnds
+	index map[Pipe]*pipeEnds
+}
+
+type pipeEnds struct {
+	producer  int
+	consumers []int
+}
+
+var recording struct {
+	sync.Mutex
+	topology *Topology
+}
+
+// Record starts recording topology of all pipelines built from now on
+// with Pipe methods, Pipeline, Merge and their variants.
+// It returns topology being recorded.
+func Record() *Topology {
+	t := &Topology{index: map[Pipe]*pipeEnds{}}
+	recording.Lock()
+	recording.topology = t
+	recording.Unlock()
+	return t
+}
+
+// StopRecording stops recording of pipelines topology
+func StopRecording() {
+	recording.Lock()
+	recording.topology = nil
+	recording.Unlock()
+}
+
+func recorded() *Topology {
+	recording.Lock()
+	defer recording.Unlock()
+	return recording.topology
+}
+
+// record adds node which consumes inputs and produces outputs to topology
+// being recorded, if any
+func record(kind, name, typ string, inputs []Pipe, outputs []Pipe) {
+	t := recorded()
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	id := t.addNode(kind, name, typ)
+	for _, p := range inputs {
+		ends := t.ends(p)
+		ends.consumers = append(ends.consumers, id)
+	}
+	for _, p := range outputs {
+		t.ends(p).producer = id
+	}
+}
+
+func recordStage(proc Processor, input, output Pipe) {
+	name, typ := describe(proc)
+	record(KindStage, name, typ, []Pipe{input}, []Pipe{output})
+}
+
+// describe returns name and type of processor, looking through wrappers
+func describe(proc Processor) (string, string) {
+	switch p := proc.(type) {
+	case *Instrumented:
+		_, typ := describe(p.Processor)
+		return p.Name, typ
+	case *Guarded:
+		_, typ := describe(p.Processor)
+		return p.Name, typ
+	case nil:
+		return "nil", "nil"
+	}
+	t := reflect.TypeOf(proc)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.ToLower(t.Name()), reflect.TypeOf(proc).String()
+}
+
+func (t *Topology) addNode(kind, name, typ string) int {
+	id := len(t.nodes)
+	t.nodes = append(t.nodes, Node{ID: id, Name: name, Kind: kind, Type: typ})
+	return id
+}
+
+// ends returns record of pipe, pipes without recorded producer are
+// considered to be fed from outside and get a source node in graph
+func (t *Topology) ends(p Pipe) *pipeEnds {
+	ends, ok := t.index[p]
+	if !ok {
+		ends = &pipeEnds{producer: -1}
+		t.index[p] = ends
+		t.pipes = append(t.pipes, ends)
+	}
+	return ends
+}
+
+// Sink marks pipe as consumed outside of pipeline, e.g. read in CollectMetrics
+func (t *Topology) Sink(p Pipe, name string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	id := t.addNode(KindSink, name, "")
+	ends := t.ends(p)
+	ends.consumers = append(ends.consumers, id)
+}
+
+// Nodes returns recorded nodes, including sources of pipes fed from outside
+func (t *Topology) Nodes() []Node {
+	nodes, _ := t.graph()
+	return nodes
+}
+
+// Edges returns recorded pipes as edges between nodes
+func (t *Topology) Edges() []Edge {
+	_, edges := t.graph()
+	return edges
+}
+
+func (t *Topology) graph() ([]Node, []Edge) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	nodes := append([]Node{}, t.nodes...)
+	edges := []Edge{}
+	sources := 0
+	for _, ends := range t.pipes {
+		from := ends.producer
+		if from == -1 {
+			from = len(nodes)
+			nodes = append(nodes, Node{ID: from, Name: fmt.Sprintf("source%d", sources), Kind: KindSource})
+			sources++
+		}
+		if len(ends.consumers) == 0 {
+			edges = append(edges, Edge{From: from, To: -1})
+		}
+		for _, to := range ends.consumers {
+			edges = append(edges, Edge{From: from, To: to})
+		}
+	}
+	return nodes, edges
+}
+
+// Validate returns error listing pipes which are produced but never consumed.
+// Such pipe blocks its producer and, in case of Destination or Clone,
+// all other branches of the same fan-out.
+// Pipes read outside of pipeline have to be marked with Sink.
+func (t *Topology) Validate() error {
+	nodes, edges := t.graph()
+	dangling := []string{}
+	for _, e := range edges {
+		if e.To == -1 {
+			n := nodes[e.From]
+			dangling = append(dangling, fmt.Sprintf("%s %s (#%d)", n.Kind, n.Name, n.ID))
+		}
+	}
+	if len(dangling) > 0 {
+		return fmt.Errorf("Pipeline has outputs without consumer: %s", strings.Join(dangling, ", "))
+	}
+	return nil
+}
+
+// JSON returns topology as JSON document with nodes and edges
+func (t *Topology) JSON() ([]byte, error) {
+	nodes, edges := t.graph()
+	return json.Marshal(struct {
+		Nodes []Node `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}{nodes, edges})
+}
+
+// DOT returns topology in Graphviz DOT format.
+// Pipes without consumer point to a red `unconsumed` node.
+func (t *Topology) DOT() string {
+	nodes, edges := t.graph()
+	var buf bytes.Buffer
+	buf.WriteString("digraph pipeline {\n")
+	buf.WriteString("\trankdir=LR;\n")
+	for _, n := range nodes {
+		label := dotEscape(n.Name)
+		if n.Type != "" {
+			label += "\\n" + dotEscape(n.Type)
+		}
+		fmt.Fprintf(&buf, "\tn%d [label=\"%s\", shape=%s];\n", n.ID, label, dotShape(n.Kind))
+	}
+	unconsumed := false
+	for _, e := range edges {
+		if e.To == -1 {
+			unconsumed = true
+			fmt.Fprintf(&buf, "\tn%d -> unconsumed [color=red];\n", e.From)
+			continue
+		}
+		fmt.Fprintf(&buf, "\tn%d -> n%d;\n", e.From, e.To)
+	}
+	if unconsumed {
+		buf.WriteString("\tunconsumed [shape=point, color=red];\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func dotEscape(s string) string {
+	return strings.Replace(strings.Replace(s, "\\", "\\\\", -1), "\"", "\\\"", -1)
+}
+
+func dotShape(kind string) string {
+	switch kind {
+	case KindSource, KindSink:
+		return "ellipse"
+	case KindStage:
+		return "box"
+	}
+	return "diamond"
+}
@@ -0,0 +1,120 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pipeline
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTopology(t *testing.T) {
+	Convey("Given recorded pipeline with fan-out", t, func() {
+		topology := Record()
+		input := make(Pipe)
+		filtered := input.Next(StringContains{Str: "sd"})
+		branches := filtered.Clone(2)
+		counted, _ := InstrumentedPipeline(branches[0], Skip{Count: 1})
+		StopRecording()
+
+		// not recorded
+		Pipeline(make(Pipe), Collect{})
+
+		Convey("Then stages, fan-out and sources are recorded", func() {
+			So(topology.Nodes(), ShouldResemble, []Node{
+				{ID: 0, Name: "stringcontains", Kind: KindStage, Type: "pipeline.StringContains"},
+				{ID: 1, Name: "destination", Kind: KindDestination},
+				{ID: 2, Name: "0_skip", Kind: KindStage, Type: "pipeline.Skip"},
+				{ID: 3, Name: "source0", Kind: KindSource},
+			})
+			So(topology.Edges(), ShouldResemble, []Edge{
+				{From: 3, To: 0},
+				{From: 0, To: 1},
+				{From: 1, To: 2},
+				{From: 1, To: -1},
+				{From: 2, To: -1},
+			})
+		})
+
+		Convey("Then branches without consumer are reported", func() {
+			err := topology.Validate()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Pipeline has outputs without consumer: destination destination (#1), stage 0_skip (#2)")
+		})
+
+		Convey("When outputs are marked as consumed", func() {
+			topology.Sink(counted, "collect_metrics")
+			topology.Sink(branches[1], "logger")
+
+			Convey("Then topology is valid", func() {
+				So(topology.Validate(), ShouldBeNil)
+			})
+
+			Convey("Then it can be exported to DOT", func() {
+				So(topology.DOT(), ShouldEqual, `digraph pipeline {
+	rankdir=LR;
+	n0 [label="stringcontains\npipeline.StringContains", shape=box];
+	n1 [label="destination", shape=diamond];
+	n2 [label="0_skip\npipeline.Skip", shape=box];
+	n3 [label="collect_metrics", shape=ellipse];
+	n4 [label="logger", shape=ellipse];
+	n5 [label="source0", shape=ellipse];
+	n5 -> n0;
+	n0 -> n1;
+	n1 -> n2;
+	n1 -> n4;
+	n2 -> n3;
+}
+`)
+			})
+
+			Convey("Then it can be exported to JSON", func() {
+				data, err := topology.JSON()
+				So(err, ShouldBeNil)
+				var doc struct {
+					Nodes []Node
+					Edges []Edge
+				}
+				So(json.Unmarshal(data, &doc), ShouldBeNil)
+				So(len(doc.Nodes), ShouldEqual, 6)
+				So(doc.Edges[0], ShouldResemble, Edge{From: 5, To: 0})
+			})
+		})
+	})
+
+	Convey("Given recorded merge, partition and tee", t, func() {
+		topology := Record()
+		a, b := make(Pipe), make(Pipe)
+		parts := Merge(a, b).Partition(func(interface{}) string { return "" }, 2)
+		branches := parts[0].Tee(Branch{})
+		Merge(parts[1], branches[0]).Destination()
+		StopRecording()
+
+		Convey("Then the graph is complete", func() {
+			So(topology.Validate(), ShouldBeNil)
+			kinds := []string{}
+			for _, n := range topology.Nodes() {
+				kinds = append(kinds, n.Kind)
+			}
+			So(kinds, ShouldResemble, []string{KindMerge, KindPartition, KindTee, KindMerge, KindDestination, KindSource, KindSource})
+		})
+	})
+}
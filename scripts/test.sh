@@ -20,7 +20,7 @@ if [[ $TEST_SUITE == "unit" ]]; then
 	
 	COVERALLS_TOKEN=t47LG6BQsfLwb9WxB56hXUezvwpED6D11
 	TEST_DIRS="./config ./ns"
-	VET_DIRS="./config/... ./logger/... ./ns/... ./pipeline/... ./source/... ./stack/..."
+	VET_DIRS="./config/... ./formula/... ./logger/... ./ns/... ./pipeline/... ./source/... ./stack/..."
 
 	set -e
 
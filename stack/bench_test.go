@@ -0,0 +1,90 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+import (
+	"testing"
+)
+
+func BenchmarkStack(b *testing.B) {
+	s := New[int](0)
+	for i := 0; i < b.N; i++ {
+		s.Push(i)
+		s.Push(i)
+		s.Pop()
+	}
+}
+
+func BenchmarkSynchronizedStack(b *testing.B) {
+	s := NewSynchronized[int](0)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Push(1)
+			s.Pop()
+		}
+	})
+}
+
+func BenchmarkQueue(b *testing.B) {
+	q := NewQueue[int](0)
+	for i := 0; i < b.N; i++ {
+		q.Push(i)
+		q.Push(i)
+		q.Pop()
+	}
+}
+
+func BenchmarkSynchronizedQueue(b *testing.B) {
+	q := NewSynchronizedQueue[int](0)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			q.Push(1)
+			q.Pop()
+		}
+	})
+}
+
+func BenchmarkDeque(b *testing.B) {
+	d := NewDeque[int](0)
+	for i := 0; i < b.N; i++ {
+		d.PushFront(i)
+		d.PushBack(i)
+		d.PopBack()
+	}
+}
+
+func BenchmarkRing(b *testing.B) {
+	r := NewRing[int](1024)
+	for i := 0; i < b.N; i++ {
+		r.Push(i)
+	}
+}
+
+func BenchmarkPriorityQueue(b *testing.B) {
+	q := NewPriorityQueue[int](0, func(a, b int) bool { return a < b })
+	for i := 0; i < 1024; i++ {
+		q.Push((i * 7919) % 1024)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q.Push((i * 7919) % 1024)
+		q.Pop()
+	}
+}
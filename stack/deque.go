@@ -0,0 +1,150 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+// pushFront prepends value, buffer must not be full
+func (c *circular[T]) pushFront(value T) {
+	c.head = (c.head - 1 + len(c.buf)) % len(c.buf)
+	c.buf[c.head] = value
+	c.count++
+}
+
+func (c *circular[T]) popBack() (value T, ok bool) {
+	if c.count == 0 {
+		return value, false
+	}
+	i := (c.head + c.count - 1) % len(c.buf)
+	value = c.buf[i]
+	var zero T
+	// drop reference, so removed value can be garbage collected
+	c.buf[i] = zero
+	c.count--
+	return value, true
+}
+
+// Deque is double-ended queue of values of type T. Zero value is an empty,
+// unbounded deque which is not safe for concurrent use.
+type Deque[T any] struct {
+	locker
+	maxSize int
+	values  circular[T]
+}
+
+// NewDeque creates deque holding at most maxSize values, 0 means no limit
+func NewDeque[T any](maxSize int) *Deque[T] {
+	return &Deque[T]{maxSize: maxSize}
+}
+
+// NewSynchronizedDeque creates deque like NewDeque which is safe for concurrent use
+func NewSynchronizedDeque[T any](maxSize int) *Deque[T] {
+	return &Deque[T]{maxSize: maxSize, locker: locker{synchronized: true}}
+}
+
+// Len returns number of values in deque
+func (d *Deque[T]) Len() int {
+	d.lock()
+	defer d.unlock()
+	return d.values.count
+}
+
+// Empty checks if deque is empty
+func (d *Deque[T]) Empty() bool {
+	return d.Len() == 0
+}
+
+// reserve makes room for one more value, returns ErrFull if deque
+// reached its maximum size
+func (d *Deque[T]) reserve() error {
+	if d.maxSize > 0 && d.values.count >= d.maxSize {
+		return ErrFull
+	}
+	if d.values.full() {
+		d.values.grow()
+	}
+	return nil
+}
+
+// PushFront prepends value at the front of deque, returns ErrFull if deque
+// reached its maximum size
+func (d *Deque[T]) PushFront(value T) error {
+	d.lock()
+	defer d.unlock()
+	if err := d.reserve(); err != nil {
+		return err
+	}
+	d.values.pushFront(value)
+	return nil
+}
+
+// PushBack appends value at the back of deque, returns ErrFull if deque
+// reached its maximum size
+func (d *Deque[T]) PushBack(value T) error {
+	d.lock()
+	defer d.unlock()
+	if err := d.reserve(); err != nil {
+		return err
+	}
+	d.values.push(value)
+	return nil
+}
+
+// PopFront takes value from the front of deque, ok is false if deque is empty
+func (d *Deque[T]) PopFront() (T, bool) {
+	d.lock()
+	defer d.unlock()
+	return d.values.pop()
+}
+
+// PopBack takes value from the back of deque, ok is false if deque is empty
+func (d *Deque[T]) PopBack() (T, bool) {
+	d.lock()
+	defer d.unlock()
+	return d.values.popBack()
+}
+
+// Peek returns n-th value from the front of deque without removing it,
+// Peek(0) is the front. ok is false if deque holds n or less values.
+func (d *Deque[T]) Peek(n int) (T, bool) {
+	d.lock()
+	defer d.unlock()
+	return d.values.peek(n)
+}
+
+// Each calls f for values from front to back until f returns false.
+// Deque must not be modified by f.
+func (d *Deque[T]) Each(f func(value T) bool) {
+	d.lock()
+	defer d.unlock()
+	d.values.each(f)
+}
+
+// Snapshot returns copy of values from front to back
+func (d *Deque[T]) Snapshot() []T {
+	d.lock()
+	defer d.unlock()
+	return d.values.snapshot()
+}
+
+// Clear removes all values from deque
+func (d *Deque[T]) Clear() {
+	d.lock()
+	defer d.unlock()
+	d.values = circular[T]{}
+}
@@ -0,0 +1,135 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeque(t *testing.T) {
+	Convey("Given zero value deque", t, func() {
+		var d Deque[int]
+
+		Convey("Then it is empty", func() {
+			So(d.Empty(), ShouldBeTrue)
+			_, ok := d.PopFront()
+			So(ok, ShouldBeFalse)
+			_, ok = d.PopBack()
+			So(ok, ShouldBeFalse)
+			So(d.Snapshot(), ShouldBeEmpty)
+		})
+
+		Convey("When values are pushed at both ends beyond initial buffer", func() {
+			for i := 0; i < 10; i++ {
+				So(d.PushBack(i), ShouldBeNil)
+				So(d.PushFront(-i-1), ShouldBeNil)
+			}
+
+			Convey("Then they are kept in order", func() {
+				So(d.Len(), ShouldEqual, 20)
+				So(d.Snapshot(), ShouldResemble, []int{-10, -9, -8, -7, -6, -5, -4, -3, -2, -1, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+				v, ok := d.Peek(10)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, 0)
+			})
+
+			Convey("Then they can be popped from both ends", func() {
+				v, ok := d.PopFront()
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, -10)
+				v, ok = d.PopBack()
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, 9)
+				So(d.Len(), ShouldEqual, 18)
+			})
+
+			Convey("Then deque can be cleared", func() {
+				d.Clear()
+				So(d.Empty(), ShouldBeTrue)
+				So(d.PushFront(1), ShouldBeNil)
+			})
+		})
+
+		Convey("When it is used as a stack from the back", func() {
+			d.PushBack(1)
+			d.PushBack(2)
+			d.PopFront()
+			d.PushBack(3)
+
+			Convey("Then values are popped in reverse order", func() {
+				v, _ := d.PopBack()
+				So(v, ShouldEqual, 3)
+				v, _ = d.PopBack()
+				So(v, ShouldEqual, 2)
+				So(d.Empty(), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given deque with maximum size", t, func() {
+		d := NewDeque[string](2)
+		d.PushBack("a")
+		d.PushFront("b")
+
+		Convey("Then overflow is reported at both ends", func() {
+			So(d.PushBack("c"), ShouldEqual, ErrFull)
+			So(d.PushFront("c"), ShouldEqual, ErrFull)
+			So(d.Snapshot(), ShouldResemble, []string{"b", "a"})
+		})
+	})
+
+	Convey("Given synchronized deque", t, func() {
+		d := NewSynchronizedDeque[int](0)
+
+		Convey("When values are pushed and popped concurrently", func() {
+			var wg sync.WaitGroup
+			popped := make(chan int, 1000)
+			for i := 0; i < 10; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						d.PushFront(j)
+						d.PushBack(j)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 25; j++ {
+						if v, ok := d.PopFront(); ok {
+							popped <- v
+						}
+						if v, ok := d.PopBack(); ok {
+							popped <- v
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			Convey("Then none is lost", func() {
+				So(d.Len()+len(popped), ShouldEqual, 1000)
+			})
+		})
+	})
+}
@@ -0,0 +1,195 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+import (
+	"sort"
+)
+
+// prioritized is value in priority queue with its insertion number,
+// which keeps FIFO order of values with equal priority
+type prioritized[T any] struct {
+	value T
+	seq   uint64
+}
+
+// PriorityQueue pops values in order given by less function, e.g. the
+// earliest due time first. Values of equal priority are popped in order
+// they were pushed. PriorityQueue has to be created with NewPriorityQueue
+// or NewSynchronizedPriorityQueue.
+type PriorityQueue[T any] struct {
+	locker
+	maxSize int
+	less    func(a, b T) bool
+	heap    []prioritized[T]
+	seq     uint64
+}
+
+// NewPriorityQueue creates priority queue holding at most maxSize values,
+// 0 means no limit. less reports whether a should be popped before b.
+func NewPriorityQueue[T any](maxSize int, less func(a, b T) bool) *PriorityQueue[T] {
+	return &PriorityQueue[T]{maxSize: maxSize, less: less}
+}
+
+// NewSynchronizedPriorityQueue creates priority queue like NewPriorityQueue
+// which is safe for concurrent use
+func NewSynchronizedPriorityQueue[T any](maxSize int, less func(a, b T) bool) *PriorityQueue[T] {
+	q := NewPriorityQueue(maxSize, less)
+	q.synchronized = true
+	return q
+}
+
+func (q *PriorityQueue[T]) before(i, j int) bool {
+	a, b := q.heap[i], q.heap[j]
+	if q.less(a.value, b.value) {
+		return true
+	}
+	if q.less(b.value, a.value) {
+		return false
+	}
+	return a.seq < b.seq
+}
+
+func (q *PriorityQueue[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !q.before(i, parent) {
+			return
+		}
+		q.heap[i], q.heap[parent] = q.heap[parent], q.heap[i]
+		i = parent
+	}
+}
+
+func (q *PriorityQueue[T]) down(i int) {
+	for {
+		first := i
+		if left := 2*i + 1; left < len(q.heap) && q.before(left, first) {
+			first = left
+		}
+		if right := 2*i + 2; right < len(q.heap) && q.before(right, first) {
+			first = right
+		}
+		if first == i {
+			return
+		}
+		q.heap[i], q.heap[first] = q.heap[first], q.heap[i]
+		i = first
+	}
+}
+
+// Len returns number of values in queue
+func (q *PriorityQueue[T]) Len() int {
+	q.lock()
+	defer q.unlock()
+	return len(q.heap)
+}
+
+// Empty checks if queue is empty
+func (q *PriorityQueue[T]) Empty() bool {
+	return q.Len() == 0
+}
+
+// Push adds value to queue, returns ErrFull if queue reached its maximum size
+func (q *PriorityQueue[T]) Push(value T) error {
+	q.lock()
+	defer q.unlock()
+	if q.maxSize > 0 && len(q.heap) >= q.maxSize {
+		return ErrFull
+	}
+	q.heap = append(q.heap, prioritized[T]{value: value, seq: q.seq})
+	q.seq++
+	q.up(len(q.heap) - 1)
+	return nil
+}
+
+// Pop takes value of the highest priority, ok is false if queue is empty
+func (q *PriorityQueue[T]) Pop() (value T, ok bool) {
+	q.lock()
+	defer q.unlock()
+	n := len(q.heap)
+	if n == 0 {
+		return value, false
+	}
+	value = q.heap[0].value
+	q.heap[0] = q.heap[n-1]
+	// drop reference, so popped value can be garbage collected
+	q.heap[n-1] = prioritized[T]{}
+	q.heap = q.heap[:n-1]
+	q.down(0)
+	return value, true
+}
+
+// Peek returns n-th value in priority order without removing it, Peek(0)
+// is the value Pop would return. ok is false if queue holds n or less values.
+// Peek of other than the first value sorts copy of the queue.
+func (q *PriorityQueue[T]) Peek(n int) (value T, ok bool) {
+	q.lock()
+	defer q.unlock()
+	if n < 0 || n >= len(q.heap) {
+		return value, false
+	}
+	if n == 0 {
+		return q.heap[0].value, true
+	}
+	return q.sorted()[n], true
+}
+
+// Each calls f for values in priority order until f returns false
+func (q *PriorityQueue[T]) Each(f func(value T) bool) {
+	for _, v := range q.Snapshot() {
+		if !f(v) {
+			return
+		}
+	}
+}
+
+// Snapshot returns copy of values in priority order
+func (q *PriorityQueue[T]) Snapshot() []T {
+	q.lock()
+	defer q.unlock()
+	return q.sorted()
+}
+
+func (q *PriorityQueue[T]) sorted() []T {
+	entries := append([]prioritized[T]{}, q.heap...)
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if q.less(a.value, b.value) {
+			return true
+		}
+		if q.less(b.value, a.value) {
+			return false
+		}
+		return a.seq < b.seq
+	})
+	values := make([]T, len(entries))
+	for i, e := range entries {
+		values[i] = e.value
+	}
+	return values
+}
+
+// Clear removes all values from queue
+func (q *PriorityQueue[T]) Clear() {
+	q.lock()
+	defer q.unlock()
+	q.heap = nil
+}
@@ -0,0 +1,98 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type run struct {
+	source string
+	due    time.Time
+}
+
+func TestPriorityQueue(t *testing.T) {
+	Convey("Given priority queue of source runs by due time", t, func() {
+		start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+		q := NewPriorityQueue[run](0, func(a, b run) bool { return a.due.Before(b.due) })
+		for _, r := range []run{
+			{"iostat", start.Add(3 * time.Second)},
+			{"du", start.Add(time.Second)},
+			{"pcm", start.Add(5 * time.Second)},
+			{"df", start.Add(time.Second)},
+			{"emon", start},
+		} {
+			So(q.Push(r), ShouldBeNil)
+		}
+
+		Convey("Then runs are popped by due time, equal ones in push order", func() {
+			sources := []string{}
+			for !q.Empty() {
+				r, ok := q.Pop()
+				So(ok, ShouldBeTrue)
+				sources = append(sources, r.source)
+			}
+			So(sources, ShouldResemble, []string{"emon", "du", "df", "iostat", "pcm"})
+			_, ok := q.Pop()
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Then runs can be peeked in priority order", func() {
+			r, ok := q.Peek(0)
+			So(ok, ShouldBeTrue)
+			So(r.source, ShouldEqual, "emon")
+			r, ok = q.Peek(3)
+			So(ok, ShouldBeTrue)
+			So(r.source, ShouldEqual, "iostat")
+			_, ok = q.Peek(5)
+			So(ok, ShouldBeFalse)
+			So(q.Len(), ShouldEqual, 5)
+		})
+
+		Convey("Then runs can be iterated in priority order", func() {
+			sources := []string{}
+			q.Each(func(r run) bool {
+				sources = append(sources, r.source)
+				return len(sources) < 2
+			})
+			So(sources, ShouldResemble, []string{"emon", "du"})
+			So(len(q.Snapshot()), ShouldEqual, 5)
+		})
+
+		Convey("Then queue can be cleared", func() {
+			q.Clear()
+			So(q.Empty(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given synchronized priority queue with maximum size", t, func() {
+		q := NewSynchronizedPriorityQueue[int](2, func(a, b int) bool { return a > b })
+		q.Push(1)
+		q.Push(2)
+
+		Convey("Then overflow is reported", func() {
+			So(q.Push(3), ShouldEqual, ErrFull)
+			So(q.Snapshot(), ShouldResemble, []int{2, 1})
+		})
+	})
+}
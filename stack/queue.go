@@ -0,0 +1,176 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+// circular is FIFO buffer of values stored in a circular slice
+type circular[T any] struct {
+	buf   []T
+	head  int
+	count int
+}
+
+func (c *circular[T]) full() bool {
+	return c.count == len(c.buf)
+}
+
+// grow doubles buffer capacity keeping order of values
+func (c *circular[T]) grow() {
+	size := 2 * len(c.buf)
+	if size == 0 {
+		size = 8
+	}
+	buf := make([]T, size)
+	c.copyTo(buf)
+	c.buf = buf
+	c.head = 0
+}
+
+func (c *circular[T]) copyTo(dst []T) {
+	end := c.head + c.count
+	if end > len(c.buf) {
+		end = len(c.buf)
+	}
+	n := copy(dst, c.buf[c.head:end])
+	copy(dst[n:], c.buf[:c.count-n])
+}
+
+// push appends value, buffer must not be full
+func (c *circular[T]) push(value T) {
+	c.buf[(c.head+c.count)%len(c.buf)] = value
+	c.count++
+}
+
+func (c *circular[T]) pop() (value T, ok bool) {
+	if c.count == 0 {
+		return value, false
+	}
+	value = c.buf[c.head]
+	var zero T
+	// drop reference, so removed value can be garbage collected
+	c.buf[c.head] = zero
+	c.head = (c.head + 1) % len(c.buf)
+	c.count--
+	return value, true
+}
+
+func (c *circular[T]) peek(n int) (value T, ok bool) {
+	if n < 0 || n >= c.count {
+		return value, false
+	}
+	return c.buf[(c.head+n)%len(c.buf)], true
+}
+
+func (c *circular[T]) each(f func(value T) bool) {
+	for i := 0; i < c.count; i++ {
+		if !f(c.buf[(c.head+i)%len(c.buf)]) {
+			return
+		}
+	}
+}
+
+func (c *circular[T]) snapshot() []T {
+	snapshot := make([]T, c.count)
+	if c.count > 0 {
+		c.copyTo(snapshot)
+	}
+	return snapshot
+}
+
+// Queue is FIFO container of values of type T. Zero value is an empty,
+// unbounded queue which is not safe for concurrent use.
+type Queue[T any] struct {
+	locker
+	maxSize int
+	values  circular[T]
+}
+
+// NewQueue creates queue holding at most maxSize values, 0 means no limit
+func NewQueue[T any](maxSize int) *Queue[T] {
+	return &Queue[T]{maxSize: maxSize}
+}
+
+// NewSynchronizedQueue creates queue like NewQueue which is safe for concurrent use
+func NewSynchronizedQueue[T any](maxSize int) *Queue[T] {
+	return &Queue[T]{maxSize: maxSize, locker: locker{synchronized: true}}
+}
+
+// Len returns number of values in queue
+func (q *Queue[T]) Len() int {
+	q.lock()
+	defer q.unlock()
+	return q.values.count
+}
+
+// Empty checks if queue is empty
+func (q *Queue[T]) Empty() bool {
+	return q.Len() == 0
+}
+
+// Push appends value at the back of queue, returns ErrFull if queue
+// reached its maximum size
+func (q *Queue[T]) Push(value T) error {
+	q.lock()
+	defer q.unlock()
+	if q.maxSize > 0 && q.values.count >= q.maxSize {
+		return ErrFull
+	}
+	if q.values.full() {
+		q.values.grow()
+	}
+	q.values.push(value)
+	return nil
+}
+
+// Pop takes value from the front of queue, ok is false if queue is empty
+func (q *Queue[T]) Pop() (T, bool) {
+	q.lock()
+	defer q.unlock()
+	return q.values.pop()
+}
+
+// Peek returns n-th value from the front of queue without removing it,
+// Peek(0) is the front. ok is false if queue holds n or less values.
+func (q *Queue[T]) Peek(n int) (T, bool) {
+	q.lock()
+	defer q.unlock()
+	return q.values.peek(n)
+}
+
+// Each calls f for values from front to back until f returns false.
+// Queue must not be modified by f.
+func (q *Queue[T]) Each(f func(value T) bool) {
+	q.lock()
+	defer q.unlock()
+	q.values.each(f)
+}
+
+// Snapshot returns copy of values from front to back
+func (q *Queue[T]) Snapshot() []T {
+	q.lock()
+	defer q.unlock()
+	return q.values.snapshot()
+}
+
+// Clear removes all values from queue
+func (q *Queue[T]) Clear() {
+	q.lock()
+	defer q.unlock()
+	q.values = circular[T]{}
+}
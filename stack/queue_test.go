@@ -0,0 +1,132 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestQueue(t *testing.T) {
+	Convey("Given zero value queue", t, func() {
+		var q Queue[int]
+
+		Convey("Then it is empty", func() {
+			So(q.Empty(), ShouldBeTrue)
+			_, ok := q.Pop()
+			So(ok, ShouldBeFalse)
+			So(q.Snapshot(), ShouldBeEmpty)
+		})
+
+		Convey("When more values are pushed than initial buffer holds", func() {
+			for i := 0; i < 5; i++ {
+				q.Push(i)
+			}
+			// move head, so values wrap around end of buffer when it grows
+			q.Pop()
+			q.Pop()
+			for i := 5; i < 20; i++ {
+				So(q.Push(i), ShouldBeNil)
+			}
+
+			Convey("Then they are popped in order", func() {
+				So(q.Len(), ShouldEqual, 18)
+				for i := 2; i < 20; i++ {
+					v, ok := q.Pop()
+					So(ok, ShouldBeTrue)
+					So(v, ShouldEqual, i)
+				}
+				So(q.Empty(), ShouldBeTrue)
+			})
+
+			Convey("Then they can be peeked from front", func() {
+				v, ok := q.Peek(0)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, 2)
+				v, ok = q.Peek(17)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, 19)
+				_, ok = q.Peek(18)
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("Then they can be iterated from front", func() {
+				seen := []int{}
+				q.Each(func(v int) bool {
+					seen = append(seen, v)
+					return v < 4
+				})
+				So(seen, ShouldResemble, []int{2, 3, 4})
+				So(q.Snapshot()[:3], ShouldResemble, []int{2, 3, 4})
+				So(len(q.Snapshot()), ShouldEqual, 18)
+			})
+
+			Convey("Then queue can be cleared", func() {
+				q.Clear()
+				So(q.Empty(), ShouldBeTrue)
+				So(q.Push(1), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given queue with maximum size", t, func() {
+		q := NewQueue[string](2)
+		q.Push("a")
+		q.Push("b")
+
+		Convey("Then overflow is reported", func() {
+			So(q.Push("c"), ShouldEqual, ErrFull)
+			So(q.Snapshot(), ShouldResemble, []string{"a", "b"})
+		})
+	})
+
+	Convey("Given synchronized queue", t, func() {
+		q := NewSynchronizedQueue[int](0)
+
+		Convey("When values are pushed and popped concurrently", func() {
+			var wg sync.WaitGroup
+			popped := make(chan int, 1000)
+			for i := 0; i < 10; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						q.Push(j)
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						if v, ok := q.Pop(); ok {
+							popped <- v
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			Convey("Then none is lost", func() {
+				So(q.Len()+len(popped), ShouldEqual, 1000)
+			})
+		})
+	})
+}
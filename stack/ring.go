@@ -0,0 +1,120 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+// Ring is FIFO buffer of fixed capacity, pushing to full ring overwrites
+// its oldest value. It keeps e.g. the last N samples. Ring has to be
+// created with NewRing or NewSynchronizedRing.
+type Ring[T any] struct {
+	locker
+	values circular[T]
+}
+
+// NewRing creates ring buffer holding last capacity values, negative
+// capacity is treated as 0
+func NewRing[T any](capacity int) *Ring[T] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &Ring[T]{values: circular[T]{buf: make([]T, capacity)}}
+}
+
+// NewSynchronizedRing creates ring buffer like NewRing which is safe for concurrent use
+func NewSynchronizedRing[T any](capacity int) *Ring[T] {
+	r := NewRing[T](capacity)
+	r.synchronized = true
+	return r
+}
+
+// Len returns number of values in ring
+func (r *Ring[T]) Len() int {
+	r.lock()
+	defer r.unlock()
+	return r.values.count
+}
+
+// Cap returns capacity of ring
+func (r *Ring[T]) Cap() int {
+	return len(r.values.buf)
+}
+
+// Empty checks if ring is empty
+func (r *Ring[T]) Empty() bool {
+	return r.Len() == 0
+}
+
+// Full checks if next Push overwrites the oldest value
+func (r *Ring[T]) Full() bool {
+	r.lock()
+	defer r.unlock()
+	return r.values.full()
+}
+
+// Push appends value, overwriting the oldest one if ring is full.
+// It returns ErrFull only for ring of zero capacity, which cannot hold any value.
+func (r *Ring[T]) Push(value T) error {
+	r.lock()
+	defer r.unlock()
+	if len(r.values.buf) == 0 {
+		return ErrFull
+	}
+	if r.values.full() {
+		r.values.pop()
+	}
+	r.values.push(value)
+	return nil
+}
+
+// Pop takes the oldest value from ring, ok is false if ring is empty
+func (r *Ring[T]) Pop() (T, bool) {
+	r.lock()
+	defer r.unlock()
+	return r.values.pop()
+}
+
+// Peek returns n-th oldest value without removing it, Peek(0) is the oldest.
+// ok is false if ring holds n or less values.
+func (r *Ring[T]) Peek(n int) (T, bool) {
+	r.lock()
+	defer r.unlock()
+	return r.values.peek(n)
+}
+
+// Each calls f for values from the oldest to the newest until f returns false.
+// Ring must not be modified by f.
+func (r *Ring[T]) Each(f func(value T) bool) {
+	r.lock()
+	defer r.unlock()
+	r.values.each(f)
+}
+
+// Snapshot returns copy of values from the oldest to the newest
+func (r *Ring[T]) Snapshot() []T {
+	r.lock()
+	defer r.unlock()
+	return r.values.snapshot()
+}
+
+// Clear removes all values from ring
+func (r *Ring[T]) Clear() {
+	r.lock()
+	defer r.unlock()
+	r.values = circular[T]{buf: make([]T, len(r.values.buf))}
+}
@@ -0,0 +1,95 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRing(t *testing.T) {
+	Convey("Given ring of capacity 3", t, func() {
+		r := NewRing[int](3)
+		So(r.Cap(), ShouldEqual, 3)
+		So(r.Empty(), ShouldBeTrue)
+
+		Convey("When it is not full", func() {
+			r.Push(1)
+			r.Push(2)
+
+			Convey("Then it holds all values", func() {
+				So(r.Full(), ShouldBeFalse)
+				So(r.Snapshot(), ShouldResemble, []int{1, 2})
+			})
+		})
+
+		Convey("When more values than capacity are pushed", func() {
+			for i := 1; i <= 5; i++ {
+				So(r.Push(i), ShouldBeNil)
+			}
+
+			Convey("Then it keeps the newest values", func() {
+				So(r.Full(), ShouldBeTrue)
+				So(r.Len(), ShouldEqual, 3)
+				So(r.Snapshot(), ShouldResemble, []int{3, 4, 5})
+				v, _ := r.Peek(2)
+				So(v, ShouldEqual, 5)
+			})
+
+			Convey("Then the oldest value is popped first", func() {
+				v, ok := r.Pop()
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, 3)
+				r.Push(6)
+				seen := []int{}
+				r.Each(func(v int) bool {
+					seen = append(seen, v)
+					return true
+				})
+				So(seen, ShouldResemble, []int{4, 5, 6})
+			})
+
+			Convey("Then ring can be cleared keeping its capacity", func() {
+				r.Clear()
+				So(r.Empty(), ShouldBeTrue)
+				So(r.Cap(), ShouldEqual, 3)
+			})
+		})
+	})
+
+	Convey("Given ring of zero capacity", t, func() {
+		r := NewSynchronizedRing[int](0)
+
+		Convey("Then it cannot hold values", func() {
+			So(r.Push(1), ShouldEqual, ErrFull)
+			So(r.Empty(), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given ring of negative capacity", t, func() {
+		r := NewRing[int](-1)
+
+		Convey("Then it has zero capacity", func() {
+			So(r.Cap(), ShouldEqual, 0)
+			So(r.Push(1), ShouldEqual, ErrFull)
+		})
+	})
+}
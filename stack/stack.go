@@ -17,51 +17,136 @@ See the License for the specific language governing permissions and
 limitations under lthe License.
 */
 
-package formula
+// Package stack provides generic containers for plugins: stack, queue,
+// ring buffer and priority queue. All of them may be bounded and
+// optionally thread-safe.
+package stack
 
-// Stack implementation as directional list
-type Stack struct {
-	top  *Element
-	size int
+import (
+	"errors"
+	"sync"
+)
+
+// ErrFull is returned when pushing to container which reached its maximum size
+var ErrFull = errors.New("Container is full")
+
+// Stack is LIFO container of values of type T. Zero value is an empty,
+// unbounded stack which is not safe for concurrent use.
+type Stack[T any] struct {
+	locker
+	maxSize int
+	items   []T
+}
+
+// New creates stack holding at most maxSize values, 0 means no limit
+func New[T any](maxSize int) *Stack[T] {
+	return &Stack[T]{maxSize: maxSize}
 }
 
-// Element from stack. Has value and points to element behind
-type Element struct {
-	value interface{}
-	next  *Element
+// NewSynchronized creates stack like New which is safe for concurrent use
+func NewSynchronized[T any](maxSize int) *Stack[T] {
+	return &Stack[T]{maxSize: maxSize, locker: locker{synchronized: true}}
+}
+
+// locker guards container with mutex if it is synchronized
+type locker struct {
+	mutex        sync.Mutex
+	synchronized bool
+}
+
+func (l *locker) lock() {
+	if l.synchronized {
+		l.mutex.Lock()
+	}
+}
+
+func (l *locker) unlock() {
+	if l.synchronized {
+		l.mutex.Unlock()
+	}
 }
 
 // Returns number of elements on stack
-func (s *Stack) Len() int {
-	return s.size
+func (s *Stack[T]) Len() int {
+	s.lock()
+	defer s.unlock()
+	return len(s.items)
 }
 
-// Push value on top of stack
-func (s *Stack) Push(value interface{}) {
-	s.top = &Element{value: value, next: s.top}
-	s.size++
+// Checks if stack is empty
+func (s *Stack[T]) Empty() bool {
+	return s.Len() == 0
 }
 
-// Take value from top of stack
-func (s *Stack) Pop() interface{} {
-	if s.size > 0 {
-		value := s.top.value
-		s.top = s.top.next
-		s.size--
-		return value
+// Push value on top of stack, returns ErrFull if stack reached its maximum size
+func (s *Stack[T]) Push(value T) error {
+	s.lock()
+	defer s.unlock()
+	if s.maxSize > 0 && len(s.items) >= s.maxSize {
+		return ErrFull
 	}
+	s.items = append(s.items, value)
 	return nil
 }
 
-// Pick value (do not take it off from stack)
-func (s *Stack) Pick() interface{} {
-	if s.size > 0 {
-		return s.top.value
+// Take value from top of stack, ok is false if stack is empty
+func (s *Stack[T]) Pop() (value T, ok bool) {
+	s.lock()
+	defer s.unlock()
+	n := len(s.items)
+	if n == 0 {
+		return value, false
 	}
-	return nil
+	value = s.items[n-1]
+	var zero T
+	// drop reference, so popped value can be garbage collected
+	s.items[n-1] = zero
+	s.items = s.items[:n-1]
+	return value, true
 }
 
-// Checks if stack is empty
-func (s *Stack) Empty() bool {
-	return s.size == 0
+// Pick value from top of stack (do not take it off from stack)
+func (s *Stack[T]) Pick() (T, bool) {
+	return s.Peek(0)
+}
+
+// Peek returns n-th value from top of stack without removing it,
+// Peek(0) is the top. ok is false if stack holds n or less values.
+func (s *Stack[T]) Peek(n int) (value T, ok bool) {
+	s.lock()
+	defer s.unlock()
+	if n < 0 || n >= len(s.items) {
+		return value, false
+	}
+	return s.items[len(s.items)-1-n], true
+}
+
+// Each calls f for values from top to bottom until f returns false.
+// Stack must not be modified by f.
+func (s *Stack[T]) Each(f func(value T) bool) {
+	s.lock()
+	defer s.unlock()
+	for i := len(s.items) - 1; i >= 0; i-- {
+		if !f(s.items[i]) {
+			return
+		}
+	}
+}
+
+// Snapshot returns copy of values from top to bottom
+func (s *Stack[T]) Snapshot() []T {
+	s.lock()
+	defer s.unlock()
+	snapshot := make([]T, len(s.items))
+	for i, v := range s.items {
+		snapshot[len(s.items)-1-i] = v
+	}
+	return snapshot
+}
+
+// Clear removes all values from stack
+func (s *Stack[T]) Clear() {
+	s.lock()
+	defer s.unlock()
+	s.items = nil
 }
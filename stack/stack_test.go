@@ -0,0 +1,123 @@
+/*
+http://www.apache.org/licenses/LICENSE-2.0.txt
+
+
+Copyright 2016 Intel Corporation
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stack
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStack(t *testing.T) {
+	Convey("Given zero value stack", t, func() {
+		var s Stack[string]
+
+		Convey("Then it is empty", func() {
+			So(s.Empty(), ShouldBeTrue)
+			_, ok := s.Pop()
+			So(ok, ShouldBeFalse)
+			_, ok = s.Pick()
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("When values are pushed", func() {
+			for _, v := range []string{"a", "b", "c"} {
+				So(s.Push(v), ShouldBeNil)
+			}
+
+			Convey("Then they are popped in reverse order", func() {
+				So(s.Len(), ShouldEqual, 3)
+				for _, expected := range []string{"c", "b", "a"} {
+					v, ok := s.Pop()
+					So(ok, ShouldBeTrue)
+					So(v, ShouldEqual, expected)
+				}
+				So(s.Empty(), ShouldBeTrue)
+			})
+
+			Convey("Then they can be peeked from top", func() {
+				v, ok := s.Pick()
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, "c")
+				v, ok = s.Peek(2)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, "a")
+				_, ok = s.Peek(3)
+				So(ok, ShouldBeFalse)
+				So(s.Len(), ShouldEqual, 3)
+			})
+
+			Convey("Then they can be iterated from top", func() {
+				seen := []string{}
+				s.Each(func(v string) bool {
+					seen = append(seen, v)
+					return v != "b"
+				})
+				So(seen, ShouldResemble, []string{"c", "b"})
+				So(s.Snapshot(), ShouldResemble, []string{"c", "b", "a"})
+			})
+
+			Convey("Then stack can be cleared", func() {
+				s.Clear()
+				So(s.Empty(), ShouldBeTrue)
+				So(s.Snapshot(), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given stack with maximum size", t, func() {
+		s := New[int](2)
+		So(s.Push(1), ShouldBeNil)
+		So(s.Push(2), ShouldBeNil)
+
+		Convey("Then overflow is reported", func() {
+			So(s.Push(3), ShouldEqual, ErrFull)
+			So(s.Snapshot(), ShouldResemble, []int{2, 1})
+		})
+
+		Convey("Then values can be pushed after pop", func() {
+			s.Pop()
+			So(s.Push(3), ShouldBeNil)
+		})
+	})
+
+	Convey("Given synchronized stack", t, func() {
+		s := NewSynchronized[int](0)
+
+		Convey("When values are pushed concurrently", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 100; j++ {
+						s.Push(j)
+					}
+				}()
+			}
+			wg.Wait()
+
+			Convey("Then none is lost", func() {
+				So(s.Len(), ShouldEqual, 1000)
+			})
+		})
+	})
+}